@@ -15,7 +15,10 @@
 package evolviconf
 
 import (
+	"errors"
+	"fmt"
 	"maps"
+	"reflect"
 	"sort"
 	"strings"
 
@@ -35,6 +38,14 @@ type Change struct {
 	// message is the log message that will be printed if a file is detected
 	// that uses this field with an unsupported version.
 	Message string
+	// ReplacedBy is the name of the field that replaces a deprecated field.
+	// If set, warnings about the deprecated field contain a fix that renames
+	// the field.
+	ReplacedBy string
+	// Default is the new default value of the field, only used by
+	// DefaultChanged changes. The value is parsed according to the type of
+	// the field (see ApplyDefaults).
+	Default string
 }
 
 // ChangeType defines the type of the change introduced in a specific version.
@@ -43,8 +54,25 @@ type ChangeType int
 const (
 	FieldDeprecated ChangeType = iota
 	FieldIntroduced
+	// DefaultChanged records that the default value of a field changed in a
+	// specific version, see ApplyDefaults.
+	DefaultChanged
 )
 
+// WarningCode returns the code of warnings about a change of this type.
+func (ct ChangeType) WarningCode() string {
+	switch ct {
+	case FieldDeprecated:
+		return CodeFieldDeprecated
+	case FieldIntroduced:
+		return CodeFieldIntroduced
+	case DefaultChanged:
+		return CodeDefaultChanged
+	default:
+		return ""
+	}
+}
+
 // Expand expands a changelog map into a structure that is useful for traversing
 // in ConfigLinter. It returns a map of all versions and their changes. The
 // changes are stored in a nested map where each token in the field is a key in
@@ -56,27 +84,43 @@ func (cl Changelog) Expand() map[*semver.Version]map[string]any {
 	}
 	sort.Sort(versions)
 
+	changes := make([][]Change, len(versions))
+	for i, v := range versions {
+		changes[i] = cl[v]
+	}
+
 	knownChanges := make(map[*semver.Version]map[string]any)
-	for _, v := range versions {
-		knownChanges[v] = make(map[string]any)
+	for i, rules := range expandChanges(versions, changes) {
+		knownChanges[versions[i]] = rules
 	}
+	return knownChanges
+}
 
-	for _, v := range versions {
-		changes := cl[v]
-		for _, v2 := range versions {
+// expandChanges expands the changes of each version into a nested map of
+// changes that apply to that version (see Changelog.Expand). Versions need to
+// be sorted in ascending order, changes[i] contains the changes introduced in
+// versions[i], versions are compared by their position.
+func expandChanges(versions []*semver.Version, changes [][]Change) []map[string]any {
+	knownChanges := make([]map[string]any, len(versions))
+	for i := range versions {
+		knownChanges[i] = make(map[string]any)
+	}
+
+	for i := range versions {
+		for j := range versions {
 			switch {
-			case !v.GreaterThan(v2):
+			case i <= j:
 				// warn about deprecated fields in future versions
-				for _, c := range changes {
+				for _, c := range changes[i] {
 					if c.ChangeType == FieldDeprecated {
-						cl.addChange(c, knownChanges[v2])
+						addChange(c, knownChanges[j])
 					}
 				}
-			case v.GreaterThan(v2):
+			default:
 				// warn about introduced fields in older versions
-				for _, c := range changes {
+				for _, c := range changes[i] {
 					if c.ChangeType == FieldIntroduced {
-						cl.addChange(c, knownChanges[v2])
+						addChange(c, knownChanges[j])
 					}
 				}
 			}
@@ -91,7 +135,7 @@ func (cl Changelog) Expand() map[*semver.Version]map[string]any {
 // hierarchy exists and is _not_ a map it is _not_ replaced. This means that
 // changes related to parent fields take precedence over changes related to
 // child fields.
-func (cl Changelog) addChange(change Change, m map[string]any) {
+func addChange(change Change, m map[string]any) {
 	tokens := strings.Split(change.Field, ".")
 	curMap := m
 	for i, t := range tokens {
@@ -113,3 +157,107 @@ func (cl Changelog) addChange(change Change, m map[string]any) {
 		}
 	}
 }
+
+// Validate checks the changelog for inconsistencies. It reports versions that
+// are defined more than once (equal versions with different pointers), fields
+// that are introduced or deprecated more than once, fields that are
+// deprecated before they are introduced, default changes without a default
+// value and malformed field paths. All
+// detected problems are returned joined in a single error.
+func (cl Changelog) Validate() error {
+	versions := cl.sortedVersions()
+
+	var errs []error
+	for i := 1; i < len(versions); i++ {
+		if versions[i-1].Equal(versions[i]) {
+			errs = append(errs, fmt.Errorf("%w: version %s is defined multiple times", ErrInvalidChangelog, versions[i]))
+		}
+	}
+
+	introduced := make(map[string]*semver.Version)
+	deprecated := make(map[string]*semver.Version)
+	for _, v := range versions {
+		for _, c := range cl[v] {
+			if err := c.validateField(); err != nil {
+				errs = append(errs, fmt.Errorf("%w: version %s: %w", ErrInvalidChangelog, v, err))
+				continue
+			}
+
+			switch c.ChangeType {
+			case FieldIntroduced:
+				if prev, ok := introduced[c.Field]; ok {
+					errs = append(errs, fmt.Errorf("%w: field %q is introduced in version %s and again in version %s", ErrInvalidChangelog, c.Field, prev, v))
+					continue
+				}
+				if prev, ok := deprecated[c.Field]; ok {
+					errs = append(errs, fmt.Errorf("%w: field %q is deprecated in version %s before it is introduced in version %s", ErrInvalidChangelog, c.Field, prev, v))
+				}
+				introduced[c.Field] = v
+			case FieldDeprecated:
+				if prev, ok := deprecated[c.Field]; ok {
+					errs = append(errs, fmt.Errorf("%w: field %q is deprecated in version %s and again in version %s", ErrInvalidChangelog, c.Field, prev, v))
+					continue
+				}
+				deprecated[c.Field] = v
+			case DefaultChanged:
+				if c.Default == "" {
+					errs = append(errs, fmt.Errorf("%w: version %s: default change of field %q has no default value", ErrInvalidChangelog, v, c.Field))
+				}
+			default:
+				errs = append(errs, fmt.Errorf("%w: version %s: field %q has unknown change type %d", ErrInvalidChangelog, v, c.Field, c.ChangeType))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ValidateType validates the changelog (see Validate) and additionally checks
+// that every field in the changelog exists in type t. Field names are taken
+// from the struct tag with the supplied key (e.g. "yaml" or "json"), the
+// wildcard "*" matches elements of maps, slices and arrays.
+func (cl Changelog) ValidateType(t reflect.Type, tag string) error {
+	errs := []error{cl.Validate()}
+	for _, v := range cl.sortedVersions() {
+		for _, c := range cl[v] {
+			if c.validateField() != nil {
+				continue // already reported by Validate
+			}
+			if _, ok := fieldByPath(t, tag, strings.Split(c.Field, ".")); !ok {
+				errs = append(errs, fmt.Errorf("%w: version %s: field %q does not exist in type %s", ErrInvalidChangelog, v, c.Field, t))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sortedVersions returns all versions in the changelog in ascending order.
+// Equal versions are ordered by their original string to keep the order
+// deterministic.
+func (cl Changelog) sortedVersions() []*semver.Version {
+	versions := make([]*semver.Version, 0, len(cl))
+	for k := range maps.Keys(cl) {
+		versions = append(versions, k)
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		if c := versions[i].Compare(versions[j]); c != 0 {
+			return c < 0
+		}
+		return versions[i].Original() < versions[j].Original()
+	})
+	return versions
+}
+
+// validateField checks that the field path is not empty and does not contain
+// empty tokens.
+func (c Change) validateField() error {
+	if c.Field == "" {
+		return errors.New("change with empty field")
+	}
+	for _, t := range strings.Split(c.Field, ".") {
+		if t == "" {
+			return fmt.Errorf("field %q contains an empty token", c.Field)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// ChangelogEntry contains all changes introduced in a single version.
+type ChangelogEntry struct {
+	Version *semver.Version
+	Changes []Change
+}
+
+// ChangelogIndex is a changelog keyed by normalized versions. Contrary to
+// Changelog, two entries for the same version (e.g. "2.1" and "2.1.0") can not
+// coexist, they are merged into a single entry. The index keeps the versions
+// in ascending order and precomputes the expanded changes (see
+// Changelog.Expand) for every version, so looking up the rules that apply to
+// a version does not require scanning the whole changelog.
+type ChangelogIndex struct {
+	// versions contains all versions in ascending order.
+	versions []*semver.Version
+	// changes contains the changes per version, the key is the normalized
+	// version (see normalizeVersion).
+	changes map[string][]Change
+	// rules contains the expanded changes for the version at the same index
+	// in versions.
+	rules []map[string]any
+	// scheme is used to order and format versions.
+	scheme VersionScheme
+}
+
+// NewChangelogIndex creates a changelog index from the supplied entries.
+// Entries with equal versions are merged in the order they are supplied.
+// Versions are ordered with SemverScheme, use WithScheme to use a different
+// scheme.
+func NewChangelogIndex(entries ...ChangelogEntry) *ChangelogIndex {
+	return newChangelogIndex(SemverScheme, entries)
+}
+
+func newChangelogIndex(scheme VersionScheme, entries []ChangelogEntry) *ChangelogIndex {
+	ci := &ChangelogIndex{
+		changes: make(map[string][]Change),
+		scheme:  scheme,
+	}
+	for _, e := range entries {
+		key := normalizeVersion(e.Version)
+		if _, ok := ci.changes[key]; !ok {
+			ci.versions = append(ci.versions, e.Version)
+			ci.changes[key] = nil
+		}
+		ci.changes[key] = append(ci.changes[key], e.Changes...)
+	}
+	slices.SortStableFunc(ci.versions, scheme.Compare)
+
+	changes := make([][]Change, len(ci.versions))
+	for i, v := range ci.versions {
+		changes[i] = ci.changes[normalizeVersion(v)]
+	}
+	ci.rules = expandChanges(ci.versions, changes)
+	return ci
+}
+
+// Index converts the changelog into a ChangelogIndex. Versions that are
+// defined multiple times are merged, the changes of each duplicate are
+// appended ordered by the original version string.
+func (cl Changelog) Index() *ChangelogIndex {
+	versions := cl.sortedVersions()
+	entries := make([]ChangelogEntry, len(versions))
+	for i, v := range versions {
+		entries[i] = ChangelogEntry{Version: v, Changes: cl[v]}
+	}
+	return NewChangelogIndex(entries...)
+}
+
+// WithScheme returns a copy of the index that orders versions with the
+// scheme and formats them in messages (e.g. in LostFields and ApplyDefaults)
+// with it.
+func (ci *ChangelogIndex) WithScheme(scheme VersionScheme) *ChangelogIndex {
+	entries := make([]ChangelogEntry, len(ci.versions))
+	for i, v := range ci.versions {
+		entries[i] = ChangelogEntry{Version: v, Changes: ci.Changes(v)}
+	}
+	return newChangelogIndex(scheme, entries)
+}
+
+// Scheme returns the version scheme of the index.
+func (ci *ChangelogIndex) Scheme() VersionScheme {
+	if ci.scheme == nil {
+		return SemverScheme
+	}
+	return ci.scheme
+}
+
+// Changelog converts the index back into a Changelog.
+func (ci *ChangelogIndex) Changelog() Changelog {
+	cl := make(Changelog, len(ci.versions))
+	for _, v := range ci.versions {
+		cl[v] = ci.Changes(v)
+	}
+	return cl
+}
+
+// Versions returns all versions in the index in ascending order.
+func (ci *ChangelogIndex) Versions() []*semver.Version {
+	return append([]*semver.Version(nil), ci.versions...)
+}
+
+// Latest returns the latest version in the index or nil if the index is
+// empty.
+func (ci *ChangelogIndex) Latest() *semver.Version {
+	if len(ci.versions) == 0 {
+		return nil
+	}
+	return ci.versions[len(ci.versions)-1]
+}
+
+// Changes returns the changes introduced in the exact version.
+func (ci *ChangelogIndex) Changes(version *semver.Version) []Change {
+	return ci.changes[normalizeVersion(version)]
+}
+
+// Rules returns the expanded changes (see Changelog.Expand) that apply to the
+// version. If the version is not part of the index, the rules of the closest
+// older version are returned. If the version is older than all versions in
+// the index, nil is returned.
+func (ci *ChangelogIndex) Rules(version *semver.Version) map[string]any {
+	// find the first version that is greater than the requested version, the
+	// version before that is the best match
+	i := sort.Search(len(ci.versions), func(i int) bool {
+		return ci.Scheme().Compare(ci.versions[i], version) > 0
+	})
+	if i == 0 {
+		return nil
+	}
+	return ci.rules[i-1]
+}
+
+// FindChange traverses the expanded changes returned by Rules and returns the
+// change that applies to the field at path. Tokens in the path that are not
+// found in the rules are matched against the wildcard ("*").
+func FindChange(rules map[string]any, path []string) (Change, bool) {
+	curMap := rules
+	last := len(path) - 1
+	for i, field := range path {
+		nextMap, ok := curMap[field]
+		if !ok {
+			nextMap, ok = curMap["*"]
+			if !ok {
+				break
+			}
+		}
+		switch v := nextMap.(type) {
+		case map[string]any:
+			curMap = v
+			continue
+		case Change:
+			if i == last {
+				return v, true
+			}
+		}
+		break
+	}
+	return Change{}, false
+}
+
+// Validate checks the index for inconsistencies, see Changelog.Validate.
+func (ci *ChangelogIndex) Validate() error {
+	return ci.Changelog().Validate()
+}
+
+// normalizeVersion returns the version without build metadata, formatted with
+// all three version components. Versions that are equal according to semver
+// precedence produce the same string.
+func normalizeVersion(v *semver.Version) string {
+	return semver.New(v.Major(), v.Minor(), v.Patch(), v.Prerelease(), "").String()
+}
@@ -15,6 +15,9 @@
 package evolviconf
 
 import (
+	"errors"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/Masterminds/semver/v3"
@@ -131,3 +134,168 @@ func TestExpandChangelog(t *testing.T) {
 		is.Equal(want[version.Original()], m)
 	}
 }
+
+func TestChangelog_Validate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		have    Changelog
+		wantErr []string
+	}{{
+		name: "valid",
+		have: Changelog{
+			semver.MustParse("1.0"): {},
+			semver.MustParse("1.1"): {{Field: "pipelines.*.title", ChangeType: FieldIntroduced}},
+			semver.MustParse("1.2"): {{Field: "pipelines.*.title", ChangeType: FieldDeprecated}},
+		},
+	}, {
+		name: "duplicate version",
+		have: Changelog{
+			semver.MustParse("2.1"):   {},
+			semver.MustParse("2.1.0"): {},
+		},
+		wantErr: []string{"invalid changelog: version 2.1.0 is defined multiple times"},
+	}, {
+		name: "introduced twice",
+		have: Changelog{
+			semver.MustParse("1.1"): {{Field: "title", ChangeType: FieldIntroduced}},
+			semver.MustParse("1.2"): {{Field: "title", ChangeType: FieldIntroduced}},
+		},
+		wantErr: []string{`invalid changelog: field "title" is introduced in version 1.1.0 and again in version 1.2.0`},
+	}, {
+		name: "deprecated twice",
+		have: Changelog{
+			semver.MustParse("1.1"): {{Field: "title", ChangeType: FieldDeprecated}},
+			semver.MustParse("1.2"): {{Field: "title", ChangeType: FieldDeprecated}},
+		},
+		wantErr: []string{`invalid changelog: field "title" is deprecated in version 1.1.0 and again in version 1.2.0`},
+	}, {
+		name: "deprecated before introduced",
+		have: Changelog{
+			semver.MustParse("1.1"): {{Field: "title", ChangeType: FieldDeprecated}},
+			semver.MustParse("1.2"): {{Field: "title", ChangeType: FieldIntroduced}},
+		},
+		wantErr: []string{`invalid changelog: field "title" is deprecated in version 1.1.0 before it is introduced in version 1.2.0`},
+	}, {
+		name: "default change without default",
+		have: Changelog{
+			semver.MustParse("1.1"): {{Field: "workers", ChangeType: DefaultChanged, Default: "2"}},
+			semver.MustParse("1.2"): {{Field: "workers", ChangeType: DefaultChanged}},
+		},
+		wantErr: []string{`invalid changelog: version 1.2.0: default change of field "workers" has no default value`},
+	}, {
+		name: "malformed fields",
+		have: Changelog{
+			semver.MustParse("1.1"): {{Field: "", ChangeType: FieldIntroduced}},
+			semver.MustParse("1.2"): {{Field: "pipelines..title", ChangeType: FieldIntroduced}},
+		},
+		wantErr: []string{
+			"invalid changelog: version 1.1.0: change with empty field",
+			`invalid changelog: version 1.2.0: field "pipelines..title" contains an empty token`,
+		},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			err := tc.have.Validate()
+			if len(tc.wantErr) == 0 {
+				is.NoErr(err)
+				return
+			}
+			is.True(errors.Is(err, ErrInvalidChangelog))
+			is.Equal(err.Error(), strings.Join(tc.wantErr, "\n"))
+		})
+	}
+}
+
+func TestChangelog_ValidateType(t *testing.T) {
+	type processor struct {
+		Type      string `yaml:"type"`
+		Condition string `yaml:"condition"`
+	}
+	type pipeline struct {
+		Title      string               `yaml:"title"`
+		Processors map[string]processor `yaml:"processors"`
+	}
+	type config struct {
+		Version   string      `yaml:"version"`
+		Pipelines []*pipeline `yaml:"pipelines"`
+	}
+
+	is := is.New(t)
+
+	valid := Changelog{
+		semver.MustParse("1.0"): {},
+		semver.MustParse("1.1"): {{Field: "pipelines.*.processors.*.condition", ChangeType: FieldIntroduced}},
+		semver.MustParse("1.2"): {{Field: "pipelines.*.processors.*.type", ChangeType: FieldDeprecated}},
+	}
+	is.NoErr(valid.ValidateType(reflect.TypeFor[config](), "yaml"))
+
+	invalid := Changelog{
+		semver.MustParse("1.1"): {{Field: "pipelines.*.name", ChangeType: FieldIntroduced}},
+		semver.MustParse("1.2"): {{Field: "pipelines.title.*", ChangeType: FieldDeprecated}},
+	}
+	err := invalid.ValidateType(reflect.TypeFor[config](), "yaml")
+	is.True(errors.Is(err, ErrInvalidChangelog))
+	is.Equal(err.Error(), `invalid changelog: version 1.1.0: field "pipelines.*.name" does not exist in type evolviconf.config
+invalid changelog: version 1.2.0: field "pipelines.title.*" does not exist in type evolviconf.config`)
+}
+
+func TestChangelogIndex(t *testing.T) {
+	is := is.New(t)
+
+	deprecated := Change{Field: "pipelines.*.name", ChangeType: FieldDeprecated, Message: "name deprecated"}
+	introduced := Change{Field: "pipelines.*.title", ChangeType: FieldIntroduced, Message: "title introduced"}
+
+	index := Changelog{
+		semver.MustParse("1.0"):   {},
+		semver.MustParse("1.1"):   {introduced},
+		semver.MustParse("1.1.0"): {deprecated}, // merged with 1.1
+		semver.MustParse("1.3"):   {},
+	}.Index()
+
+	is.Equal(len(index.Versions()), 3)
+	is.Equal(index.Latest().String(), "1.3.0")
+	is.Equal(index.Changes(semver.MustParse("1.1.0")), []Change{introduced, deprecated})
+	is.Equal(len(index.Changelog()), 3)
+
+	// older than all versions
+	is.Equal(index.Rules(semver.MustParse("0.9")), nil)
+	// exact match
+	is.Equal(index.Rules(semver.MustParse("1.0")), map[string]any{
+		"pipelines": map[string]any{"*": map[string]any{"title": introduced}},
+	})
+	// best match is 1.1, build metadata is ignored
+	want := map[string]any{
+		"pipelines": map[string]any{"*": map[string]any{"name": deprecated}},
+	}
+	is.Equal(index.Rules(semver.MustParse("1.2.5")), want)
+	is.Equal(index.Rules(semver.MustParse("1.1.0+build")), want)
+	// newer than all versions
+	is.Equal(index.Rules(semver.MustParse("2.0")), want)
+}
+
+func TestChangelogIndex_Prerelease(t *testing.T) {
+	is := is.New(t)
+
+	introduced := Change{Field: "pipelines.*.title", ChangeType: FieldIntroduced, Message: "title introduced"}
+
+	index := Changelog{
+		semver.MustParse("2.2.0"):        {},
+		semver.MustParse("2.3.0-beta.1"): {introduced},
+		semver.MustParse("2.3.0"):        {},
+	}.Index()
+
+	is.Equal(index.Latest().String(), "2.3.0")
+
+	want := map[string]any{
+		"pipelines": map[string]any{"*": map[string]any{"title": introduced}},
+	}
+	// the field is not known before the pre-release it was introduced in
+	is.Equal(index.Rules(semver.MustParse("2.2.0")), want)
+	is.Equal(index.Rules(semver.MustParse("2.3.0-alpha.1")), want)
+	// it is known in the pre-release and all later versions
+	is.Equal(index.Rules(semver.MustParse("2.3.0-beta.1")), map[string]any{})
+	is.Equal(index.Rules(semver.MustParse("2.3.0-beta.2+build.5")), map[string]any{})
+	is.Equal(index.Rules(semver.MustParse("2.3.0")), map[string]any{})
+}
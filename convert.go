@@ -0,0 +1,236 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// ConfigFromer can be implemented by versioned configs (usually with a
+// pointer receiver) to populate the versioned config from the config for the
+// supplied version. It is the reverse of VersionedConfig.ToConfig. Only fields
+// supported in version should be populated, information that can't be
+// represented in version should be reported in the returned warnings.
+type ConfigFromer[T any] interface {
+	FromConfig(config T, version *semver.Version) (Warnings, error)
+}
+
+// Upgrader can be implemented by versioned configs to convert them directly
+// to the versioned config of the next parser (ordered by the latest known
+// version), without going through the config.
+type Upgrader[T any] interface {
+	Upgrade() (VersionedConfig[T], Warnings, error)
+}
+
+// Downgrader can be implemented by versioned configs to convert them directly
+// to the versioned config of the previous parser (ordered by the latest known
+// version), without going through the config. Fields that can't be
+// represented in the older versioned config should be reported in the
+// returned warnings.
+type Downgrader[T any] interface {
+	Downgrade() (VersionedConfig[T], Warnings, error)
+}
+
+// VersionSetter can be implemented by versioned configs that store their
+// version (e.g. in a version field). Parser.Convert and
+// Parser.ConvertVersioned set the version of the converted config to the
+// target version, formatted with the version scheme of the parser.
+type VersionSetter[T any] interface {
+	WithVersion(version string) VersionedConfig[T]
+}
+
+// VersionedConfigConverter can be implemented by a VersionedConfigParser to
+// support Parser.Convert and Parser.ConvertVersioned.
+type VersionedConfigConverter[T any] interface {
+	// FromConfig converts the config to the versioned config of the parser
+	// for the supplied version.
+	FromConfig(ctx context.Context, config T, version *semver.Version) (VersionedConfig[T], Warnings, error)
+	// LostFields returns warnings about fields in the versioned config
+	// converted from version from, which are not supported in the older
+	// version to and are lost. It is called on the parser for version to,
+	// from is nil if the versioned config was converted from the config.
+	LostFields(config VersionedConfig[T], from, to *semver.Version) Warnings
+}
+
+// Convert converts the config to the versioned config of the supplied
+// version, e.g. to emit configs for clients that only understand an older
+// version. The parser for the version needs to implement
+// VersionedConfigConverter. Fields of the converted config that are not
+// supported in the version are reported as warnings (see
+// VersionedConfigConverter.LostFields).
+func (p *Parser[T, D]) Convert(ctx context.Context, config T, version *semver.Version) (VersionedConfig[T], Warnings, error) {
+	parser, _ := p.findVersionedConfigParser(version)
+	if parser == nil {
+		return nil, nil, fmt.Errorf("%w %s", ErrUnsupportedVersion, p.formatVersion(version))
+	}
+	converter, ok := parser.(VersionedConfigConverter[T])
+	if !ok {
+		return nil, nil, fmt.Errorf("parser for version %s does not support converting configs", p.formatVersion(version))
+	}
+	out, warnings, err := converter.FromConfig(ctx, config, version)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to convert config to version %s: %w", p.formatVersion(version), err)
+	}
+	warnings = append(warnings, converter.LostFields(out, nil, version)...)
+	return p.setVersion(out, version), warnings, nil
+}
+
+// ConvertVersioned converts a versioned config with version from to the
+// versioned config of version to. The config is converted one parser at a
+// time with Upgrader or Downgrader, if a versioned config doesn't implement
+// them it is converted through the config with Convert. Versions handled by
+// the same parser share the versioned config, so it is returned as is, apart
+// from the version (see VersionSetter). When converting to an older version,
+// fields of the converted config that are lost according to the changelog of
+// the parser for version to are reported as warnings (see
+// VersionedConfigConverter.LostFields), Convert reports them if the config is
+// converted through the config.
+func (p *Parser[T, D]) ConvertVersioned(ctx context.Context, config VersionedConfig[T], from, to *semver.Version) (VersionedConfig[T], Warnings, error) {
+	fromIndex, _ := p.findVersionedConfigParserIndex(from)
+	toIndex, _ := p.findVersionedConfigParserIndex(to)
+	if fromIndex < 0 {
+		return nil, nil, fmt.Errorf("%w %s", ErrUnsupportedVersion, p.formatVersion(from))
+	}
+	if toIndex < 0 {
+		return nil, nil, fmt.Errorf("%w %s", ErrUnsupportedVersion, p.formatVersion(to))
+	}
+
+	var warnings Warnings
+	order := p.sortedConfigParsers()
+	i := slices.Index(order, fromIndex)
+	j := slices.Index(order, toIndex)
+	for i != j {
+		var (
+			next VersionedConfig[T]
+			w    Warnings
+			err  error
+		)
+		if i < j {
+			u, ok := config.(Upgrader[T])
+			if !ok {
+				break
+			}
+			next, w, err = u.Upgrade()
+			i++
+		} else {
+			d, ok := config.(Downgrader[T])
+			if !ok {
+				break
+			}
+			next, w, err = d.Downgrade()
+			i--
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to convert versioned config to parser for version %s: %w", p.formatVersion(p.configParsers[order[i]].LatestKnownVersion()), err)
+		}
+		config = next
+		warnings = append(warnings, w...)
+	}
+	if i != j {
+		// convert through the config if the versioned configs don't support
+		// converting them directly
+		out, w, err := toConfig(ctx, config)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to convert versioned config to actual config: %w", err)
+		}
+		warnings = append(warnings, w...)
+		config, w, err = p.Convert(ctx, out, to)
+		if err != nil {
+			return nil, nil, err
+		}
+		return config, append(warnings, w...), nil
+	}
+
+	if p.versionScheme.Compare(to, from) < 0 {
+		if converter, ok := p.configParsers[toIndex].(VersionedConfigConverter[T]); ok {
+			warnings = append(warnings, converter.LostFields(config, from, to)...)
+		}
+	}
+	return p.setVersion(config, to), warnings, nil
+}
+
+// setVersion sets the version of the versioned config to version formatted
+// with the version scheme of the parser, if it implements VersionSetter.
+func (p *Parser[T, D]) setVersion(config VersionedConfig[T], version *semver.Version) VersionedConfig[T] {
+	if s, ok := config.(VersionSetter[T]); ok {
+		return s.WithVersion(p.formatVersion(version))
+	}
+	return config
+}
+
+// sortedConfigParsers returns the indices of the config parsers ordered by
+// their latest known version.
+func (p *Parser[T, D]) sortedConfigParsers() []int {
+	order := make([]int, len(p.configParsers))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return p.versionScheme.Compare(p.configParsers[a].LatestKnownVersion(), p.configParsers[b].LatestKnownVersion())
+	})
+	return order
+}
+
+// LostFields returns warnings about fields that are set in config and are
+// not supported in version to according to the changelog, because they were
+// introduced in a newer version. Field names are read from the struct tags
+// with key tag (e.g. "yaml"). It can be used to implement
+// VersionedConfigConverter.LostFields.
+func LostFields(config any, tag string, changelog *ChangelogIndex, to *semver.Version) Warnings {
+	d := differ{opts: DiffOptions{Tag: tag}}
+	rules := changelog.Rules(to)
+	var warnings Warnings
+	var walk func(path []string, v any)
+	walk = func(path []string, v any) {
+		if v == nil || reflect.ValueOf(v).IsZero() {
+			// fields with zero values are not set
+			return
+		}
+		if len(path) > 0 {
+			if c, ok := FindChange(rules, path); ok && c.ChangeType == FieldIntroduced {
+				warnings = append(warnings, Warning{
+					Position: Position{Field: path[len(path)-1]},
+					Message:  fmt.Sprintf("field %s is not supported in version %s and is lost", strings.Join(path, "."), changelog.Scheme().FormatVersion(to)),
+					Code:     CodeFieldLost,
+				})
+				return
+			}
+		}
+		switch vv := v.(type) {
+		case map[string]any:
+			keys := make([]string, 0, len(vv))
+			for k := range vv {
+				keys = append(keys, k)
+			}
+			slices.Sort(keys)
+			for _, k := range keys {
+				walk(appendPath(path, k), vv[k])
+			}
+		case []any:
+			for i, e := range vv {
+				walk(appendPath(path, strconv.Itoa(i)), e)
+			}
+		}
+	}
+	walk(nil, d.normalize(reflect.ValueOf(config)))
+	return warnings
+}
@@ -0,0 +1,150 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/matryer/is"
+)
+
+func TestLostFields(t *testing.T) {
+	is := is.New(t)
+
+	type processor struct {
+		ID        string `yaml:"id"`
+		Condition string `yaml:"condition"`
+	}
+	type config struct {
+		Name       string      `yaml:"name"`
+		Title      string      `yaml:"title"`
+		Processors []processor `yaml:"processors"`
+	}
+
+	changelog := Changelog{
+		semver.MustParse("1.0"): {},
+		semver.MustParse("1.1"): {{Field: "title", ChangeType: FieldIntroduced}},
+		semver.MustParse("1.2"): {{Field: "processors.*.condition", ChangeType: FieldIntroduced}},
+	}.Index()
+
+	cfg := config{
+		Name:  "a",
+		Title: "b",
+		Processors: []processor{
+			{ID: "p1"},
+			{ID: "p2", Condition: "true"},
+		},
+	}
+
+	is.Equal(LostFields(cfg, "yaml", changelog, semver.MustParse("1.0")), Warnings{{
+		Position: Position{Field: "condition"},
+		Message:  "field processors.1.condition is not supported in version 1.0 and is lost",
+		Code:     CodeFieldLost,
+	}, {
+		Position: Position{Field: "title"},
+		Message:  "field title is not supported in version 1.0 and is lost",
+		Code:     CodeFieldLost,
+	}})
+	is.Equal(len(LostFields(cfg, "yaml", changelog, semver.MustParse("1.1"))), 1)
+	is.Equal(len(LostFields(cfg, "yaml", changelog, semver.MustParse("1.2"))), 0)
+}
+
+func TestParser_Convert_Unsupported(t *testing.T) {
+	is := is.New(t)
+	parser := NewParser[testConfig, *json.Decoder](newTestParser("^1", "1.1"))
+
+	_, _, err := parser.Convert(context.Background(), testConfig{}, semver.MustParse("1.0"))
+	is.Equal(err.Error(), "parser for version 1.0 does not support converting configs")
+
+	_, _, err = parser.ConvertVersioned(context.Background(), testConfig{}, semver.MustParse("1.0"), semver.MustParse("2.0"))
+	is.True(errors.Is(err, ErrUnsupportedVersion))
+
+	// versions of the same parser share the versioned config
+	got, warnings, err := parser.ConvertVersioned(context.Background(), testConfig{Name: "a"}, semver.MustParse("1.1"), semver.MustParse("1.0"))
+	is.NoErr(err)
+	is.Equal(got, testConfig{Name: "a"})
+	is.Equal(len(warnings), 0)
+}
+
+// convertingParser is a testParser that converts configs. The fields in lost
+// are introduced in the latest version of the parser and lost in older
+// versions. The slice field makes it non-comparable.
+type convertingParser struct {
+	testParser
+	lost []string
+}
+
+// versionedTestConfig is a testConfig that stores the version it was
+// converted to.
+type versionedTestConfig struct {
+	testConfig
+}
+
+func (c versionedTestConfig) WithVersion(version string) VersionedConfig[testConfig] {
+	c.Version = version
+	return c
+}
+
+func (p convertingParser) FromConfig(_ context.Context, config testConfig, _ *semver.Version) (VersionedConfig[testConfig], Warnings, error) {
+	return versionedTestConfig{config}, nil, nil
+}
+
+func (p convertingParser) LostFields(_ VersionedConfig[testConfig], _, to *semver.Version) Warnings {
+	if !to.LessThan(p.latest) {
+		return nil
+	}
+	warnings := make(Warnings, len(p.lost))
+	for i, f := range p.lost {
+		warnings[i] = Warning{Message: "field " + f + " is lost in version " + to.Original(), Code: CodeFieldLost}
+	}
+	return warnings
+}
+
+func TestParser_Convert_LostFields(t *testing.T) {
+	is := is.New(t)
+	parser := NewParser[testConfig, *json.Decoder](
+		convertingParser{testParser: newTestParser("^1", "1.1"), lost: []string{"one"}},
+	)
+
+	// fields the converted config can't hold are reported by the parser
+	got, warnings, err := parser.Convert(context.Background(), testConfig{Name: "a"}, semver.MustParse("1.0"))
+	is.NoErr(err)
+	is.Equal(got, versionedTestConfig{testConfig{Version: "1.0", Name: "a"}})
+	is.Equal(warnings, Warnings{{Message: "field one is lost in version 1.0", Code: CodeFieldLost}})
+}
+
+func TestParser_ConvertVersioned_TargetParser(t *testing.T) {
+	is := is.New(t)
+	parser := NewParser[testConfig, *json.Decoder](
+		convertingParser{testParser: newTestParser("^1", "1.1"), lost: []string{"one"}},
+		convertingParser{testParser: newTestParser("^2", "2.0"), lost: []string{"two"}},
+	)
+
+	// lost fields are reported by the parser of the target version
+	got, warnings, err := parser.ConvertVersioned(context.Background(), testConfig{Version: "2.0", Name: "a"}, semver.MustParse("2.0"), semver.MustParse("1.0"))
+	is.NoErr(err)
+	is.Equal(got, versionedTestConfig{testConfig{Version: "1.0", Name: "a"}})
+	is.Equal(warnings, Warnings{{Message: "field one is lost in version 1.0", Code: CodeFieldLost}})
+
+	// upgrades don't lose fields
+	got, warnings, err = parser.ConvertVersioned(context.Background(), testConfig{Version: "1.0", Name: "a"}, semver.MustParse("1.0"), semver.MustParse("2.0"))
+	is.NoErr(err)
+	is.Equal(got, versionedTestConfig{testConfig{Version: "2.0", Name: "a"}})
+	is.Equal(len(warnings), 0)
+}
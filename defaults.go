@@ -0,0 +1,270 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"context"
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// Defaults returns the DefaultChanged changes that are in effect in version,
+// keyed by field. For each field the change of the newest version that is
+// not newer than version is returned.
+func (ci *ChangelogIndex) Defaults(version *semver.Version) map[string]Change {
+	defaults := make(map[string]Change)
+	for _, v := range ci.versions {
+		if ci.Scheme().Compare(v, version) > 0 {
+			break
+		}
+		for _, c := range ci.Changes(v) {
+			if c.ChangeType == DefaultChanged {
+				defaults[c.Field] = c
+			}
+		}
+	}
+	return defaults
+}
+
+// nextDefaults returns the first DefaultChanged change of each field that is
+// introduced in a version newer than version, keyed by field.
+func (ci *ChangelogIndex) nextDefaults(version *semver.Version) map[string]Change {
+	next := make(map[string]Change)
+	for _, v := range ci.versions {
+		if ci.Scheme().Compare(v, version) <= 0 {
+			continue
+		}
+		for _, c := range ci.Changes(v) {
+			if _, ok := next[c.Field]; !ok && c.ChangeType == DefaultChanged {
+				next[c.Field] = c
+			}
+		}
+	}
+	return next
+}
+
+// ApplyDefaults sets the fields of config that are not set to the defaults in
+// effect in version, as recorded by DefaultChanged changes in the changelog
+// (see ChangelogIndex.Defaults). Config needs to be a pointer to a versioned
+// config, field names are taken from the struct tags with key tag (e.g.
+// "yaml" or "json") and wildcards ("*") match elements of maps, slices and
+// arrays.
+//
+// A field is treated as not set if it contains the zero value, use pointer
+// fields to distinguish explicit zero values from missing fields. Defaults
+// are only applied to fields whose parent exists, nil structs are not
+// allocated.
+//
+// For each field that is not set and whose default changes in a newer
+// version, a warning with the code CodeDefaultChanged is returned, so that
+// users relying on the old default are notified before updating the config
+// version. The warning is positioned at the closest parent of the field found
+// in the positions carried by ctx (see FieldPositionsFromContext).
+//
+// Defaults are parsed according to the type of the field. Strings, booleans,
+// integers, floats, time.Duration, pointers to those and types implementing
+// encoding.TextUnmarshaler are supported.
+func ApplyDefaults(ctx context.Context, config any, tag string, changelog *ChangelogIndex, version *semver.Version) (Warnings, error) {
+	rv := reflect.ValueOf(config)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return nil, fmt.Errorf("failed to apply defaults: expected non-nil pointer, got %T", config)
+	}
+
+	defaults := changelog.Defaults(version)
+	next := changelog.nextDefaults(version)
+
+	fields := make([]string, 0, len(defaults)+len(next))
+	for f := range defaults {
+		fields = append(fields, f)
+	}
+	for f := range next {
+		if _, ok := defaults[f]; !ok {
+			fields = append(fields, f)
+		}
+	}
+	sort.Strings(fields)
+
+	var (
+		positions = FieldPositionsFromContext(ctx)
+		warnings  Warnings
+		errs      []error
+	)
+	for _, field := range fields {
+		current, hasCurrent := defaults[field]
+		upcoming, hasUpcoming := next[field]
+		walkFieldValues(rv, tag, nil, strings.Split(field, "."), func(path []string, v reflect.Value) {
+			if !v.IsZero() {
+				return
+			}
+			p := strings.Join(path, ".")
+			if hasUpcoming {
+				msg := fmt.Sprintf("field %s is not set: %s", p, upcoming.Message)
+				if hasCurrent {
+					msg = fmt.Sprintf("field %s is not set and defaults to %s in version %s: %s", p, current.Default, changelog.Scheme().FormatVersion(version), upcoming.Message)
+				}
+				warnings = append(warnings, Warning{
+					Position: closestPosition(positions, path),
+					Message:  msg,
+					Code:     CodeDefaultChanged,
+				})
+			}
+			if hasCurrent {
+				if err := setDefault(v, current.Default); err != nil {
+					errs = append(errs, fmt.Errorf("failed to apply default of field %s: %w", p, err))
+				}
+			}
+		})
+	}
+	return warnings, errors.Join(errs...)
+}
+
+// closestPosition returns the position of the field at path or its closest
+// parent found in positions.
+func closestPosition(positions FieldPositions, path []string) Position {
+	for i := len(path); i > 0; i-- {
+		if pos, ok := positions[strings.Join(path[:i], ".")]; ok {
+			return pos
+		}
+	}
+	return Position{}
+}
+
+// walkFieldValues calls fn for every settable value in v that matches the
+// field path tokens. Path contains the concrete path to v, wildcards are
+// replaced with map keys and slice indices.
+func walkFieldValues(v reflect.Value, tag string, path, tokens []string, fn func(path []string, v reflect.Value)) {
+	if len(tokens) == 0 {
+		if v.CanSet() {
+			fn(path, v)
+		}
+		return
+	}
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	token, rest := tokens[0], tokens[1:]
+	switch v.Kind() { //nolint:exhaustive // other kinds don't contain fields
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			if token != "*" && token != strconv.Itoa(i) {
+				continue
+			}
+			walkFieldValues(v.Index(i), tag, appendPath(path, strconv.Itoa(i)), rest, fn)
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int {
+			return strings.Compare(fmt.Sprint(a.Interface()), fmt.Sprint(b.Interface()))
+		})
+		for _, k := range keys {
+			name := fmt.Sprint(k.Interface())
+			if token != "*" && token != name {
+				continue
+			}
+			// map values are not addressable, modify a copy and store it back
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(k))
+			walkFieldValues(elem, tag, appendPath(path, name), rest, fn)
+			v.SetMapIndex(k, elem)
+		}
+	case reflect.Struct:
+		for _, f := range structFields(v.Type(), tag) {
+			if f.Name != token {
+				continue
+			}
+			fv, err := v.FieldByIndexErr(f.Index)
+			if err != nil {
+				// field in nil embedded struct
+				return
+			}
+			walkFieldValues(fv, tag, appendPath(path, f.Name), rest, fn)
+			return
+		}
+	}
+}
+
+var (
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+	durationType        = reflect.TypeFor[time.Duration]()
+)
+
+// setDefault parses the default value s according to the type of v and
+// stores it in v.
+func setDefault(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Pointer {
+		p := reflect.New(v.Type().Elem())
+		if err := setDefault(p.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+	if v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() { //nolint:exhaustive // unsupported kinds return an error
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/matryer/is"
+)
+
+type defaultsDLQ struct {
+	WindowSize *int          `yaml:"window-size" evolvi:"default@2.0=1,default@2.2=4"`
+	Plugin     string        `yaml:"plugin" evolvi:"default@2.0=builtin:log"`
+	Timeout    time.Duration `yaml:"timeout" evolvi:"default@2.1=10s"`
+}
+
+type defaultsPipeline struct {
+	DLQ     defaultsDLQ  `yaml:"dead-letter-queue"`
+	Backup  *defaultsDLQ `yaml:"backup"`
+	Workers int          `yaml:"workers" evolvi:"default@2.0=1"`
+}
+
+type defaultsConfig struct {
+	Pipelines []defaultsPipeline          `yaml:"pipelines"`
+	Named     map[string]defaultsPipeline `yaml:"named"`
+}
+
+func defaultsChangelog(t *testing.T) *ChangelogIndex {
+	cl, err := ChangelogFromTags(reflect.TypeFor[defaultsConfig](), "yaml", semver.MustParse("2.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cl.Index()
+}
+
+func TestChangelogFromTags_Defaults(t *testing.T) {
+	is := is.New(t)
+	index := defaultsChangelog(t)
+
+	is.Equal(index.Defaults(semver.MustParse("2.1"))["pipelines.*.dead-letter-queue.window-size"], Change{
+		Field:      "pipelines.*.dead-letter-queue.window-size",
+		ChangeType: DefaultChanged,
+		Message:    "the default of field window-size is 1 in version 2.0",
+		Default:    "1",
+	})
+	is.Equal(index.Defaults(semver.MustParse("2.2"))["pipelines.*.dead-letter-queue.window-size"].Default, "4")
+	is.Equal(len(index.Defaults(semver.MustParse("1.0"))), 0)
+	is.NoErr(index.Validate())
+}
+
+func TestApplyDefaults(t *testing.T) {
+	is := is.New(t)
+	index := defaultsChangelog(t)
+
+	five := 5
+	cfg := defaultsConfig{
+		Pipelines: []defaultsPipeline{
+			{},
+			{DLQ: defaultsDLQ{WindowSize: &five, Plugin: "builtin:file"}, Workers: 3},
+		},
+		Named: map[string]defaultsPipeline{"p": {}},
+	}
+	warnings, err := ApplyDefaults(context.Background(), &cfg, "yaml", index, semver.MustParse("2.0"))
+	is.NoErr(err)
+
+	one := 1
+	want := defaultsConfig{
+		Pipelines: []defaultsPipeline{
+			{DLQ: defaultsDLQ{WindowSize: &one, Plugin: "builtin:log"}, Workers: 1},
+			{DLQ: defaultsDLQ{WindowSize: &five, Plugin: "builtin:file"}, Workers: 3},
+		},
+		Named: map[string]defaultsPipeline{
+			"p": {DLQ: defaultsDLQ{WindowSize: &one, Plugin: "builtin:log"}, Workers: 1},
+		},
+	}
+	is.Equal(cfg, want)
+
+	is.Equal(warnings, Warnings{{
+		Message: "field named.p.dead-letter-queue.timeout is not set: the default of field timeout is 10s in version 2.1",
+		Code:    CodeDefaultChanged,
+	}, {
+		Message: "field named.p.dead-letter-queue.window-size is not set and defaults to 1 in version 2.0: the default of field window-size changes to 4 in version 2.2",
+		Code:    CodeDefaultChanged,
+	}, {
+		Message: "field pipelines.0.dead-letter-queue.timeout is not set: the default of field timeout is 10s in version 2.1",
+		Code:    CodeDefaultChanged,
+	}, {
+		Message: "field pipelines.1.dead-letter-queue.timeout is not set: the default of field timeout is 10s in version 2.1",
+		Code:    CodeDefaultChanged,
+	}, {
+		Message: "field pipelines.0.dead-letter-queue.window-size is not set and defaults to 1 in version 2.0: the default of field window-size changes to 4 in version 2.2",
+		Code:    CodeDefaultChanged,
+	}})
+}
+
+func TestApplyDefaults_LatestVersion(t *testing.T) {
+	is := is.New(t)
+	index := defaultsChangelog(t)
+
+	cfg := defaultsConfig{Pipelines: []defaultsPipeline{{}}}
+	warnings, err := ApplyDefaults(context.Background(), &cfg, "yaml", index, semver.MustParse("2.2"))
+	is.NoErr(err)
+	is.Equal(len(warnings), 0)
+
+	dlq := cfg.Pipelines[0].DLQ
+	is.Equal(*dlq.WindowSize, 4)
+	is.Equal(dlq.Timeout, 10*time.Second)
+	// nil structs are not allocated
+	is.Equal(cfg.Pipelines[0].Backup, nil)
+}
+
+func TestApplyDefaults_Map(t *testing.T) {
+	is := is.New(t)
+	index := Changelog{
+		semver.MustParse("1.0"): {{Field: "named.*.workers", ChangeType: DefaultChanged, Default: "2"}},
+	}.Index()
+
+	cfg := defaultsConfig{Named: map[string]defaultsPipeline{"a": {}, "b": {Workers: 5}}}
+	_, err := ApplyDefaults(context.Background(), &cfg, "yaml", index, semver.MustParse("1.0"))
+	is.NoErr(err)
+	is.Equal(cfg.Named["a"].Workers, 2)
+	is.Equal(cfg.Named["b"].Workers, 5)
+}
+
+func TestApplyDefaults_Errors(t *testing.T) {
+	is := is.New(t)
+	index := Changelog{
+		semver.MustParse("1.0"): {{Field: "pipelines.*.workers", ChangeType: DefaultChanged, Default: "many"}},
+	}.Index()
+
+	cfg := defaultsConfig{Pipelines: []defaultsPipeline{{}}}
+	_, err := ApplyDefaults(context.Background(), &cfg, "yaml", index, semver.MustParse("1.0"))
+	is.Equal(err.Error(), `failed to apply default of field pipelines.0.workers: strconv.ParseInt: parsing "many": invalid syntax`)
+
+	_, err = ApplyDefaults(context.Background(), cfg, "yaml", index, semver.MustParse("1.0"))
+	is.Equal(err.Error(), "failed to apply defaults: expected non-nil pointer, got evolviconf.defaultsConfig")
+}
+
+func TestGenerateJSONSchema_Defaults(t *testing.T) {
+	is := is.New(t)
+	index := defaultsChangelog(t)
+
+	schema, err := GenerateJSONSchema(reflect.TypeFor[defaultsConfig](), "yaml", semver.MustParse("2.1"), index)
+	is.NoErr(err)
+
+	dlq := schema.Properties["pipelines"].Items.Properties["dead-letter-queue"]
+	is.Equal(dlq.Properties["window-size"].Default, int64(1))
+	is.Equal(dlq.Properties["plugin"].Default, "builtin:log")
+	is.Equal(dlq.Properties["timeout"].Default, "10s")
+}
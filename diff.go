@@ -0,0 +1,377 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DiffType defines the type of a Difference.
+type DiffType int
+
+const (
+	DiffAdded DiffType = iota
+	DiffRemoved
+	DiffModified
+)
+
+func (t DiffType) String() string {
+	switch t {
+	case DiffAdded:
+		return "added"
+	case DiffRemoved:
+		return "removed"
+	case DiffModified:
+		return "modified"
+	default:
+		return fmt.Sprintf("DiffType(%d)", int(t))
+	}
+}
+
+// Difference is a single difference between two configs.
+type Difference struct {
+	// Path is the path to the field that changed, tokens are separated by dots
+	// like in Change.Field. Slice elements are identified by their index (in
+	// the new config, or the old config if the element was removed).
+	Path string
+	Type DiffType
+	// Old is the value in the old config, nil if the field was added.
+	Old any
+	// New is the value in the new config, nil if the field was removed.
+	New any
+	// Position is the position of the field in the new config (or the old
+	// config if the field was removed). It is only set if the positions are
+	// supplied in DiffOptions.
+	Position
+}
+
+func (d Difference) String() string {
+	switch d.Type {
+	case DiffAdded:
+		return fmt.Sprintf("added %s: %v", d.Path, d.New)
+	case DiffRemoved:
+		return fmt.Sprintf("removed %s: %v", d.Path, d.Old)
+	default:
+		return fmt.Sprintf("modified %s: %v -> %v", d.Path, d.Old, d.New)
+	}
+}
+
+// Log logs the difference with its path, values and position as attributes.
+// Values are logged as they are, use LogRedactor or LogRedactedValues to
+// redact sensitive values (e.g. tokens or resolved secrets).
+func (d Difference) Log(ctx context.Context, logger *slog.Logger, opts ...LogOption) {
+	o := newLogOptions(opts)
+	path := strings.Split(d.Path, ".")
+	args := []any{slog.String("path", d.Path)}
+
+	if d.Type != DiffAdded {
+		args = append(args, slog.Any("old", o.redactValue(path, d.Old)))
+	}
+	if d.Type != DiffRemoved {
+		args = append(args, slog.Any("new", o.redactValue(path, d.New)))
+	}
+	if d.Line != 0 {
+		args = append(args, slog.Int("line", d.Line))
+	}
+	if d.Column != 0 {
+		args = append(args, slog.Int("column", d.Column))
+	}
+
+	logger.InfoContext(ctx, "config field "+d.Type.String(), args...)
+}
+
+type Differences []Difference
+
+// Log logs all differences, see Difference.Log.
+func (d Differences) Log(ctx context.Context, logger *slog.Logger, opts ...LogOption) {
+	for _, dd := range d {
+		dd.Log(ctx, logger, opts...)
+	}
+}
+
+// redactValue returns RedactedValue if the value at path needs to be
+// redacted. Normalized maps and slices (see Diff) are copied with their
+// sensitive children redacted.
+func (o logOptions) redactValue(path []string, v any) any {
+	if v == nil {
+		return nil
+	}
+	if o.redactValues || o.redactor.Sensitive(path) {
+		return RedactedValue
+	}
+	if o.redactor == nil {
+		return v
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, vv := range v {
+			out[k] = o.redactValue(append(slices.Clip(path), k), vv)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, vv := range v {
+			out[i] = o.redactValue(append(slices.Clip(path), strconv.Itoa(i)), vv)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// DiffOptions configure how Diff compares configs.
+type DiffOptions struct {
+	// Tag is the key of the struct tag that contains the field names (e.g.
+	// "yaml" or "json"), defaults to "yaml".
+	Tag string
+	// SliceKey is the name of a field that identifies elements in slices of
+	// structs or maps (e.g. "id"). If set, elements are matched by the value
+	// of this field instead of their index, so adding or removing an element
+	// only produces a single difference. Elements without the field are
+	// matched by index.
+	SliceKey string
+	// OldPositions and NewPositions contain the positions of the fields in
+	// the source of the old and new config, keyed by the path (see
+	// Difference.Path). They are used to populate Difference.Position.
+	OldPositions map[string]Position
+	NewPositions map[string]Position
+}
+
+// Diff compares the configs old and new field by field and returns the
+// differences ordered by path (fields sorted by name, slice elements in
+// order). The configs don't need to have the same type, fields are compared
+// by their names, so versioned configs of different versions can be compared
+// as well. Added and removed structs, maps and slice
+// elements are reported as a single difference containing the whole value.
+// Values in differences are normalized: structs and maps are represented as
+// map[string]any and slices as []any.
+func Diff(old, new any, opts DiffOptions) Differences {
+	if opts.Tag == "" {
+		opts.Tag = "yaml"
+	}
+	d := differ{opts: opts}
+	d.diff(nil, d.normalize(reflect.ValueOf(old)), d.normalize(reflect.ValueOf(new)))
+	return d.out
+}
+
+type differ struct {
+	opts DiffOptions
+	out  Differences
+}
+
+// normalize converts v into a tree of map[string]any, []any and scalar
+// values. Empty maps and slices are normalized to nil.
+func (d *differ) normalize(v reflect.Value) any {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() { //nolint:exhaustive // everything else is a scalar
+	case reflect.Invalid:
+		return nil
+	case reflect.Struct:
+		fields := structFields(v.Type(), d.opts.Tag)
+		if len(fields) == 0 {
+			// structs without fields (e.g. time.Time) are compared as values
+			return v.Interface()
+		}
+		out := make(map[string]any, len(fields))
+		for _, f := range fields {
+			fv, err := v.FieldByIndexErr(f.Index)
+			if err != nil {
+				// field in nil embedded struct
+				continue
+			}
+			if n := d.normalize(fv); n != nil {
+				out[f.Name] = n
+			}
+		}
+		if len(out) == 0 {
+			return nil
+		}
+		return out
+	case reflect.Map:
+		if v.Len() == 0 {
+			return nil
+		}
+		out := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out[fmt.Sprint(iter.Key().Interface())] = d.normalize(iter.Value())
+		}
+		return out
+	case reflect.Slice, reflect.Array:
+		if v.Len() == 0 {
+			return nil
+		}
+		out := make([]any, v.Len())
+		for i := range v.Len() {
+			out[i] = d.normalize(v.Index(i))
+		}
+		return out
+	default:
+		if !v.CanInterface() {
+			return nil
+		}
+		return v.Interface()
+	}
+}
+
+func (d *differ) diff(path []string, old, new any) {
+	switch {
+	case old == nil && new == nil:
+		return
+	case old == nil:
+		d.add(DiffAdded, path, nil, new)
+		return
+	case new == nil:
+		d.add(DiffRemoved, path, old, nil)
+		return
+	}
+
+	oldMap, oldIsMap := old.(map[string]any)
+	newMap, newIsMap := new.(map[string]any)
+	if oldIsMap && newIsMap {
+		d.diffMaps(path, oldMap, newMap)
+		return
+	}
+
+	oldSlice, oldIsSlice := old.([]any)
+	newSlice, newIsSlice := new.([]any)
+	if oldIsSlice && newIsSlice {
+		d.diffSlices(path, oldSlice, newSlice)
+		return
+	}
+
+	if !reflect.DeepEqual(old, new) {
+		d.add(DiffModified, path, old, new)
+	}
+}
+
+func (d *differ) diffMaps(path []string, old, new map[string]any) {
+	keys := make([]string, 0, len(old)+len(new))
+	for k := range old {
+		keys = append(keys, k)
+	}
+	for k := range new {
+		if _, ok := old[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		d.diff(appendPath(path, k), old[k], new[k])
+	}
+}
+
+func (d *differ) diffSlices(path []string, old, new []any) {
+	oldKeys, okOld := d.sliceKeys(old)
+	newKeys, okNew := d.sliceKeys(new)
+	if !okOld || !okNew {
+		// match elements by index
+		for i := range max(len(old), len(new)) {
+			var o, n any
+			if i < len(old) {
+				o = old[i]
+			}
+			if i < len(new) {
+				n = new[i]
+			}
+			d.diff(appendPath(path, strconv.Itoa(i)), o, n)
+		}
+		return
+	}
+
+	// match elements by key, report removed elements with their old index
+	// and all other elements with their new index
+	newIndex := make(map[string]int, len(newKeys))
+	for i, k := range newKeys {
+		newIndex[k] = i
+	}
+	oldIndex := make(map[string]int, len(oldKeys))
+	for i, k := range oldKeys {
+		oldIndex[k] = i
+		if _, ok := newIndex[k]; !ok {
+			d.add(DiffRemoved, appendPath(path, strconv.Itoa(i)), old[i], nil)
+		}
+	}
+	for i, k := range newKeys {
+		p := appendPath(path, strconv.Itoa(i))
+		j, ok := oldIndex[k]
+		if !ok {
+			d.add(DiffAdded, p, nil, new[i])
+			continue
+		}
+		d.diff(p, old[j], new[i])
+	}
+}
+
+// sliceKeys returns the values of the slice key field of all elements. It
+// returns false if the slice key is not configured, or if any element does
+// not contain the key or the keys are not unique.
+func (d *differ) sliceKeys(s []any) ([]string, bool) {
+	if d.opts.SliceKey == "" {
+		return nil, false
+	}
+	keys := make([]string, len(s))
+	seen := make(map[string]bool, len(s))
+	for i, e := range s {
+		m, ok := e.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		k, ok := m[d.opts.SliceKey]
+		if !ok {
+			return nil, false
+		}
+		keys[i] = fmt.Sprint(k)
+		if seen[keys[i]] {
+			return nil, false
+		}
+		seen[keys[i]] = true
+	}
+	return keys, true
+}
+
+func (d *differ) add(typ DiffType, path []string, old, new any) {
+	diff := Difference{
+		Path: strings.Join(path, "."),
+		Type: typ,
+		Old:  old,
+		New:  new,
+	}
+	if typ == DiffRemoved {
+		diff.Position = d.opts.OldPositions[diff.Path]
+	} else {
+		diff.Position = d.opts.NewPositions[diff.Path]
+	}
+	d.out = append(d.out, diff)
+}
+
+func appendPath(path []string, token string) []string {
+	out := make([]string, len(path), len(path)+1)
+	copy(out, path)
+	return append(out, token)
+}
@@ -0,0 +1,221 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+type diffTestProcessor struct {
+	ID       string            `yaml:"id"`
+	Type     string            `yaml:"type"`
+	Settings map[string]string `yaml:"settings"`
+}
+
+type diffTestPipeline struct {
+	ID         string              `yaml:"id"`
+	Status     string              `yaml:"status"`
+	Processors []diffTestProcessor `yaml:"processors"`
+	Workers    *int                `yaml:"workers"`
+}
+
+type diffTestConfig struct {
+	Version   string             `yaml:"version"`
+	Pipelines []diffTestPipeline `yaml:"pipelines"`
+}
+
+// diffTestConfigV2 has different types than diffTestConfig, but the same
+// field names.
+type diffTestConfigV2 struct {
+	Version   string `yaml:"version"`
+	Pipelines []struct {
+		ID     string `yaml:"id"`
+		Status string `yaml:"status"`
+		Name   string `yaml:"name"`
+	} `yaml:"pipelines"`
+}
+
+func TestDiff(t *testing.T) {
+	is := is.New(t)
+	workers := 2
+
+	old := diffTestConfig{
+		Version: "2.0",
+		Pipelines: []diffTestPipeline{{
+			ID:     "p1",
+			Status: "running",
+			Processors: []diffTestProcessor{{
+				ID:       "proc1",
+				Type:     "js",
+				Settings: map[string]string{"script": "a", "timeout": "1s"},
+			}},
+		}, {
+			ID:     "p2",
+			Status: "stopped",
+		}},
+	}
+	new := diffTestConfig{
+		Version: "2.0",
+		Pipelines: []diffTestPipeline{{
+			ID:      "p1",
+			Status:  "stopped",
+			Workers: &workers,
+			Processors: []diffTestProcessor{{
+				ID:       "proc1",
+				Type:     "js",
+				Settings: map[string]string{"script": "b", "retries": "3"},
+			}},
+		}},
+	}
+
+	got := Diff(old, new, DiffOptions{})
+	is.Equal(got, Differences{
+		{Path: "pipelines.0.processors.0.settings.retries", Type: DiffAdded, New: "3"},
+		{Path: "pipelines.0.processors.0.settings.script", Type: DiffModified, Old: "a", New: "b"},
+		{Path: "pipelines.0.processors.0.settings.timeout", Type: DiffRemoved, Old: "1s"},
+		{Path: "pipelines.0.status", Type: DiffModified, Old: "running", New: "stopped"},
+		{Path: "pipelines.0.workers", Type: DiffAdded, New: 2},
+		{Path: "pipelines.1", Type: DiffRemoved, Old: map[string]any{"id": "p2", "status": "stopped"}},
+	})
+
+	// no differences between equal configs
+	is.Equal(len(Diff(old, old, DiffOptions{})), 0)
+}
+
+func TestDiff_SliceKey(t *testing.T) {
+	is := is.New(t)
+
+	old := diffTestConfig{
+		Pipelines: []diffTestPipeline{{ID: "p1"}, {ID: "p2"}, {ID: "p3", Status: "running"}},
+	}
+	new := diffTestConfig{
+		Pipelines: []diffTestPipeline{{ID: "p1"}, {ID: "p3", Status: "stopped"}, {ID: "p4"}},
+	}
+
+	// matched by index, removing an element modifies all following elements
+	got := Diff(old, new, DiffOptions{})
+	is.Equal(got, Differences{
+		{Path: "pipelines.1.id", Type: DiffModified, Old: "p2", New: "p3"},
+		{Path: "pipelines.1.status", Type: DiffModified, Old: "", New: "stopped"},
+		{Path: "pipelines.2.id", Type: DiffModified, Old: "p3", New: "p4"},
+		{Path: "pipelines.2.status", Type: DiffModified, Old: "running", New: ""},
+	})
+
+	// matched by key
+	got = Diff(old, new, DiffOptions{SliceKey: "id"})
+	is.Equal(got, Differences{
+		{Path: "pipelines.1", Type: DiffRemoved, Old: map[string]any{"id": "p2", "status": ""}},
+		{Path: "pipelines.1.status", Type: DiffModified, Old: "running", New: "stopped"},
+		{Path: "pipelines.2", Type: DiffAdded, New: map[string]any{"id": "p4", "status": ""}},
+	})
+}
+
+func TestDiff_DifferentTypes(t *testing.T) {
+	is := is.New(t)
+
+	old := diffTestConfig{
+		Version:   "1.0",
+		Pipelines: []diffTestPipeline{{ID: "p1", Status: "running"}},
+	}
+	var new diffTestConfigV2
+	new.Version = "2.0"
+	new.Pipelines = append(new.Pipelines, struct {
+		ID     string `yaml:"id"`
+		Status string `yaml:"status"`
+		Name   string `yaml:"name"`
+	}{ID: "p1", Status: "running", Name: "pipeline"})
+
+	got := Diff(old, new, DiffOptions{})
+	is.Equal(got, Differences{
+		{Path: "pipelines.0.name", Type: DiffAdded, New: "pipeline"},
+		{Path: "version", Type: DiffModified, Old: "1.0", New: "2.0"},
+	})
+}
+
+func TestDiff_Positions(t *testing.T) {
+	is := is.New(t)
+
+	old := diffTestConfig{Pipelines: []diffTestPipeline{{ID: "p1"}, {ID: "p2"}}}
+	new := diffTestConfig{Pipelines: []diffTestPipeline{{ID: "p3"}}}
+
+	got := Diff(old, new, DiffOptions{
+		OldPositions: map[string]Position{
+			"pipelines.1": {Field: "1", Line: 4, Column: 5},
+		},
+		NewPositions: map[string]Position{
+			"pipelines.0.id": {Field: "id", Line: 3, Column: 7, Value: "p3"},
+		},
+	})
+	is.Equal(got, Differences{
+		{Path: "pipelines.0.id", Type: DiffModified, Old: "p1", New: "p3", Position: Position{Field: "id", Line: 3, Column: 7, Value: "p3"}},
+		{Path: "pipelines.1", Type: DiffRemoved, Old: map[string]any{"id": "p2", "status": ""}, Position: Position{Field: "1", Line: 4, Column: 5}},
+	})
+
+	var out bytes.Buffer
+	got.Log(context.Background(), slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})))
+	is.Equal(out.String(), `{"level":"INFO","msg":"config field modified","path":"pipelines.0.id","old":"p1","new":"p3","line":3,"column":7}
+{"level":"INFO","msg":"config field removed","path":"pipelines.1","old":{"id":"p2","status":""},"line":4,"column":5}
+`)
+}
+
+func TestDifferences_Log_Redacted(t *testing.T) {
+	is := is.New(t)
+	type connector struct {
+		ID       string            `yaml:"id"`
+		Settings map[string]string `yaml:"settings"`
+	}
+	type config struct {
+		Token      string      `yaml:"token"`
+		Connectors []connector `yaml:"connectors"`
+	}
+
+	got := Diff(
+		config{Token: "old-token"},
+		config{Token: "new-token", Connectors: []connector{{ID: "c1", Settings: map[string]string{"aws.secret": "s3cr3t", "aws.region": "eu"}}}},
+		DiffOptions{},
+	)
+
+	logged := func(opts ...LogOption) string {
+		var out bytes.Buffer
+		got.Log(context.Background(), slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{
+			ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.TimeKey {
+					return slog.Attr{}
+				}
+				return a
+			},
+		})), opts...)
+		return out.String()
+	}
+
+	is.Equal(logged(LogRedactor(NewRedactor("token", "**.settings.*secret*"))), `{"level":"INFO","msg":"config field added","path":"connectors","new":[{"id":"c1","settings":{"aws.region":"eu","aws.secret":"******"}}]}
+{"level":"INFO","msg":"config field modified","path":"token","old":"******","new":"******"}
+`)
+	is.Equal(logged(LogRedactedValues()), `{"level":"INFO","msg":"config field added","path":"connectors","new":"******"}
+{"level":"INFO","msg":"config field modified","path":"token","old":"******","new":"******"}
+`)
+}
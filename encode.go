@@ -0,0 +1,163 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// Encoder writes documents to a stream, e.g. *yaml.Encoder or
+// *json.Encoder. If the encoder implements io.Closer, it is closed after all
+// documents are written.
+type Encoder interface {
+	Encode(v any) error
+}
+
+// EncoderProvider is the counterpart of DecoderProvider used by
+// Parser.Encode.
+type EncoderProvider interface {
+	Encoder(io.Writer) Encoder
+}
+
+// VersionedConfigEncoder can be implemented by a VersionedConfigParser to
+// control how its versioned configs are encoded, e.g. to set the version
+// field of the document. Versioned configs of parsers that don't implement
+// it are passed to Encoder.Encode as is.
+type VersionedConfigEncoder[T any] interface {
+	EncodeVersionedConfig(ctx context.Context, encoder Encoder, config VersionedConfig[T], version *semver.Version) error
+}
+
+// WithEncoderProvider sets the provider of the encoder used by Encode. If the
+// decoder provider of the parser implements EncoderProvider, it is used by
+// default.
+func (p *Parser[T, D]) WithEncoderProvider(encoderProvider EncoderProvider) *Parser[T, D] {
+	p.encoderProvider = encoderProvider
+	return p
+}
+
+// Encode is the inverse of Parse, it converts the configs to the versioned
+// configs of the supplied version (see Convert) and writes them as documents
+// to writer. If version is nil, the latest known version is used. The
+// returned warnings contain the warnings produced by the conversion,
+// including fields that are lost because the version doesn't support them.
+func (p *Parser[T, D]) Encode(ctx context.Context, writer io.Writer, version *semver.Version, configs ...T) (Warnings, error) {
+	if p.encoderProvider == nil {
+		return nil, errors.New("parser has no encoder provider")
+	}
+	if version == nil {
+		version = p.latestVersion
+	}
+	parser, _ := p.findVersionedConfigParser(version)
+	if parser == nil {
+		return nil, fmt.Errorf("%w %s", ErrUnsupportedVersion, p.formatVersion(version))
+	}
+
+	encoder := p.encoderProvider.Encoder(writer)
+	var warnings Warnings
+	for i, config := range configs {
+		vc, w, err := p.Convert(ctx, config, version)
+		if err != nil {
+			return nil, err
+		}
+		warnings = append(warnings, w...)
+
+		if e, ok := parser.(VersionedConfigEncoder[T]); ok {
+			err = e.EncodeVersionedConfig(ctx, encoder, vc, version)
+		} else {
+			err = encoder.Encode(vc)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode config %d: %w", i, err)
+		}
+	}
+
+	if c, ok := encoder.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			return nil, fmt.Errorf("failed to close encoder: %w", err)
+		}
+	}
+	return warnings, nil
+}
+
+// EmptyFields returns the paths of the fields in config that are empty, so
+// that encoders can omit them. Fields are empty if they contain the zero
+// value or an empty map or slice. Pointer fields are only empty if they are
+// nil, so explicit zero values are kept. Elements of maps and slices are
+// never empty, as omitting them would change the container, but fields of
+// their elements can be. Field names are taken from the struct tags with key
+// tag (e.g. "yaml"), map keys are formatted with fmt.Sprint and elements of
+// slices are referenced by their index. Fields of empty fields are not
+// included.
+func EmptyFields(config any, tag string) [][]string {
+	var empty [][]string
+	var walk func(path []string, v reflect.Value)
+	walk = func(path []string, v reflect.Value) {
+		for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return
+			}
+			v = v.Elem()
+		}
+		switch v.Kind() { //nolint:exhaustive // other kinds don't contain fields
+		case reflect.Struct:
+			for _, f := range structFields(v.Type(), tag) {
+				fv, err := v.FieldByIndexErr(f.Index)
+				if err != nil {
+					// nil embedded pointer
+					continue
+				}
+				fieldPath := appendPath(path, f.Name)
+				if isEmptyValue(fv) {
+					empty = append(empty, fieldPath)
+					continue
+				}
+				walk(fieldPath, fv)
+			}
+		case reflect.Map:
+			keys := v.MapKeys()
+			slices.SortFunc(keys, func(a, b reflect.Value) int {
+				return strings.Compare(fmt.Sprint(a.Interface()), fmt.Sprint(b.Interface()))
+			})
+			for _, k := range keys {
+				walk(appendPath(path, fmt.Sprint(k.Interface())), v.MapIndex(k))
+			}
+		case reflect.Slice, reflect.Array:
+			for i := range v.Len() {
+				walk(appendPath(path, strconv.Itoa(i)), v.Index(i))
+			}
+		}
+	}
+	walk(nil, reflect.ValueOf(config))
+	return empty
+}
+
+// isEmptyValue returns true if v is the zero value or an empty map or slice.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() { //nolint:exhaustive // other kinds are empty if they are zero
+	case reflect.Map, reflect.Slice:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/matryer/is"
+)
+
+// encodingTestParser is a testParser that supports converting and encoding
+// configs.
+type encodingTestParser struct {
+	testParser
+}
+
+func (p encodingTestParser) Encoder(w io.Writer) Encoder {
+	return json.NewEncoder(w)
+}
+
+func (p encodingTestParser) FromConfig(_ context.Context, config testConfig, _ *semver.Version) (VersionedConfig[testConfig], Warnings, error) {
+	return config, nil, nil
+}
+
+func (p encodingTestParser) LostFields(VersionedConfig[testConfig], *semver.Version, *semver.Version) Warnings {
+	return nil
+}
+
+func (p encodingTestParser) EncodeVersionedConfig(_ context.Context, enc Encoder, config VersionedConfig[testConfig], version *semver.Version) error {
+	c := config.(testConfig)
+	c.Version = version.String()
+	return enc.Encode(c)
+}
+
+func TestParser_Encode(t *testing.T) {
+	is := is.New(t)
+	parser := NewParser[testConfig, *json.Decoder](
+		encodingTestParser{newTestParser("^1", "1.1")},
+		encodingTestParser{newTestParser("^2", "2.0")},
+	)
+
+	var buf bytes.Buffer
+	warnings, err := parser.Encode(context.Background(), &buf, nil, testConfig{Name: "a"}, testConfig{Version: "1.0", Name: "b"})
+	is.NoErr(err)
+	is.Equal(len(warnings), 0)
+	is.Equal(buf.String(), `{"version":"2.0.0","name":"a"}`+"\n"+`{"version":"2.0.0","name":"b"}`+"\n")
+
+	buf.Reset()
+	_, err = parser.Encode(context.Background(), &buf, semver.MustParse("1.1"), testConfig{Name: "a"})
+	is.NoErr(err)
+
+	got, _, err := parser.Parse(context.Background(), &buf)
+	is.NoErr(err)
+	is.Equal(got, []testConfig{{Version: "1.1.0", Name: "a", ParsedVersion: "1.1.0"}})
+
+	_, err = parser.Encode(context.Background(), &buf, semver.MustParse("3.0"), testConfig{})
+	is.True(errors.Is(err, ErrUnsupportedVersion))
+}
+
+func TestParser_Encode_NoEncoder(t *testing.T) {
+	is := is.New(t)
+	parser := NewParser[testConfig, *json.Decoder](newTestParser("^1", "1.1"))
+
+	_, err := parser.Encode(context.Background(), io.Discard, nil, testConfig{})
+	is.Equal(err.Error(), "parser has no encoder provider")
+}
+
+func TestEmptyFields(t *testing.T) {
+	is := is.New(t)
+
+	type processor struct {
+		ID      string `yaml:"id"`
+		Workers int    `yaml:"workers"`
+		Limit   *int   `yaml:"limit"`
+	}
+	type config struct {
+		Name       string               `yaml:"name"`
+		Settings   map[string]string    `yaml:"settings"`
+		Processors []processor          `yaml:"processors"`
+		Named      map[string]processor `yaml:"named"`
+		Tags       []string             `yaml:"tags"`
+	}
+
+	zero := 0
+	got := EmptyFields(config{
+		Settings:   map[string]string{"a": ""},
+		Processors: []processor{{ID: "p1", Limit: &zero}, {}},
+		Named:      map[string]processor{"b": {Workers: 2}},
+		Tags:       []string{},
+	}, "yaml")
+	is.Equal(got, [][]string{
+		{"name"},
+		// explicit zero value of limit is not empty
+		{"processors", "0", "workers"},
+		{"processors", "1", "id"},
+		{"processors", "1", "workers"},
+		{"processors", "1", "limit"},
+		{"named", "b", "id"},
+		{"named", "b", "limit"},
+		{"tags"},
+	})
+}
@@ -16,4 +16,9 @@ package evolviconf
 
 import "errors"
 
-var ErrVersionNotSpecified = errors.New("version not specified")
+var (
+	ErrVersionNotSpecified = errors.New("version not specified")
+	ErrInvalidChangelog    = errors.New("invalid changelog")
+	ErrKindNotSpecified    = errors.New("kind not specified")
+	ErrUnsupportedVersion  = errors.New("unsupported version")
+)
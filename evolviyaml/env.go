@@ -0,0 +1,265 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviyaml
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/conduitio/evolviconf"
+	"github.com/conduitio/yaml/v3"
+)
+
+// EnvExpander expands environment variables in string values with shell-like
+// syntax:
+//   - $VAR and ${VAR} are replaced with the value of VAR.
+//   - ${VAR:-default} uses default if VAR is unset or empty, ${VAR-default}
+//     only if VAR is unset. The default can contain variables as well.
+//   - ${VAR:?message} reports an error if VAR is unset or empty,
+//     ${VAR?message} only if VAR is unset.
+//   - $$ is replaced with a literal $.
+//
+// Secret references (e.g. "${secret:name}") are left untouched, see
+// Parser.WithSecretResolver. Unlike EnvDecoderHook, unset variables are
+// reported as warnings with the code evolviconf.CodeEnvVarNotSet.
+type EnvExpander struct {
+	lookup        func(name string) (string, bool)
+	unsetSeverity evolviconf.Severity
+}
+
+// NewEnvExpander creates an expander that looks up variables with
+// os.LookupEnv.
+func NewEnvExpander() *EnvExpander {
+	return &EnvExpander{
+		lookup:        os.LookupEnv,
+		unsetSeverity: evolviconf.SeverityWarning,
+	}
+}
+
+// WithLookup sets the function used to look up variables, it returns false
+// if the variable is not set. The default is os.LookupEnv.
+func (e *EnvExpander) WithLookup(lookup func(name string) (string, bool)) *EnvExpander {
+	e.lookup = lookup
+	return e
+}
+
+// WithUnsetSeverity sets the severity of warnings about unset variables
+// without a default, the default is evolviconf.SeverityWarning. Use
+// evolviconf.SeverityError to treat every unset variable as required.
+func (e *EnvExpander) WithUnsetSeverity(severity evolviconf.Severity) *EnvExpander {
+	e.unsetSeverity = severity
+	return e
+}
+
+// DecoderHook returns a hook that expands variables in string values and
+// appends warnings about unset variables and invalid references to warn.
+func (e *EnvExpander) DecoderHook(warn *evolviconf.Warnings) yaml.DecoderHook {
+	return e.decoderHook(warn, nil)
+}
+
+// decoderHook returns the hook returned by DecoderHook, warnings about
+// sensitive fields are redacted with redactor.
+func (e *EnvExpander) decoderHook(warn *evolviconf.Warnings, redactor *evolviconf.Redactor) yaml.DecoderHook {
+	return func(path []string, node *yaml.Node) {
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!str" || !strings.Contains(node.Value, "$") {
+			return
+		}
+		var problems []envProblem
+		expanded := e.expand(node.Value, &problems)
+		for _, p := range problems {
+			var field string
+			if len(path) > 0 {
+				field = path[len(path)-1]
+			}
+			*warn = append(*warn, redactor.RedactWarning(evolviconf.Warning{
+				Position: evolviconf.Position{
+					Field:  field,
+					Line:   node.Line,
+					Column: node.Column,
+					Value:  node.Value,
+				},
+				Message:  p.message,
+				Severity: p.severity,
+				Code:     p.code,
+			}, path))
+		}
+		if expanded != node.Value {
+			node.SetString(expanded)
+		}
+	}
+}
+
+// Expand expands the variables in s, see EnvExpander. It returns the
+// expanded string and the problems found as warnings without a position.
+func (e *EnvExpander) Expand(s string) (string, evolviconf.Warnings) {
+	var problems []envProblem
+	out := e.expand(s, &problems)
+	var warn evolviconf.Warnings
+	for _, p := range problems {
+		warn = append(warn, evolviconf.Warning{Message: p.message, Severity: p.severity, Code: p.code})
+	}
+	return out, warn
+}
+
+// envProblem is an unset variable or invalid reference found while expanding
+// a string.
+type envProblem struct {
+	message  string
+	severity evolviconf.Severity
+	code     string
+}
+
+func (e *EnvExpander) expand(s string, problems *[]envProblem) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+
+		next := s[i+1]
+		switch {
+		case next == '$':
+			b.WriteByte('$')
+			i += 2
+		case next == '{':
+			end := closingBrace(s, i+1)
+			if end < 0 {
+				*problems = append(*problems, envProblem{
+					message:  fmt.Sprintf("unterminated variable reference %q", s[i:]),
+					severity: evolviconf.SeverityError,
+					code:     evolviconf.CodeEnvVarInvalid,
+				})
+				b.WriteString(s[i:])
+				return b.String()
+			}
+			expr := s[i+2 : end]
+			if strings.HasPrefix(expr, "secret:") {
+				// secret reference, resolved by the secret resolver
+				b.WriteString(s[i : end+1])
+			} else {
+				b.WriteString(e.expandExpr(expr, problems))
+			}
+			i = end + 1
+		case isEnvNameStart(next):
+			j := i + 2
+			for j < len(s) && isEnvNameChar(s[j]) {
+				j++
+			}
+			b.WriteString(e.lookupVar(s[i+1:j], problems))
+			i = j
+		default:
+			b.WriteByte('$')
+			i++
+		}
+	}
+	return b.String()
+}
+
+// expandExpr expands the expression inside of ${...}.
+func (e *EnvExpander) expandExpr(expr string, problems *[]envProblem) string {
+	n := 0
+	for n < len(expr) && isEnvNameChar(expr[n]) {
+		n++
+	}
+	name, op := expr[:n], expr[n:]
+	if name == "" || !isEnvNameStart(name[0]) {
+		*problems = append(*problems, envProblem{
+			message:  fmt.Sprintf("invalid variable reference \"${%s}\"", expr),
+			severity: evolviconf.SeverityError,
+			code:     evolviconf.CodeEnvVarInvalid,
+		})
+		return ""
+	}
+	if op == "" {
+		return e.lookupVar(name, problems)
+	}
+
+	v, ok := e.lookup(name)
+	// the colon variants treat empty variables like unset ones
+	unset := !ok
+	if strings.HasPrefix(op, ":") {
+		unset = v == ""
+		op = op[1:]
+	}
+	switch {
+	case strings.HasPrefix(op, "-"):
+		if unset {
+			return e.expand(op[1:], problems)
+		}
+		return v
+	case strings.HasPrefix(op, "?"):
+		if unset {
+			msg := fmt.Sprintf("required environment variable %s is not set", name)
+			if op[1:] != "" {
+				msg += ": " + op[1:]
+			}
+			*problems = append(*problems, envProblem{
+				message:  msg,
+				severity: evolviconf.SeverityError,
+				code:     evolviconf.CodeEnvVarNotSet,
+			})
+		}
+		return v
+	default:
+		*problems = append(*problems, envProblem{
+			message:  fmt.Sprintf("invalid variable reference \"${%s}\"", expr),
+			severity: evolviconf.SeverityError,
+			code:     evolviconf.CodeEnvVarInvalid,
+		})
+		return ""
+	}
+}
+
+// lookupVar returns the value of the variable and reports it if it's unset.
+func (e *EnvExpander) lookupVar(name string, problems *[]envProblem) string {
+	v, ok := e.lookup(name)
+	if !ok {
+		*problems = append(*problems, envProblem{
+			message:  fmt.Sprintf("environment variable %s is not set", name),
+			severity: e.unsetSeverity,
+			code:     evolviconf.CodeEnvVarNotSet,
+		})
+	}
+	return v
+}
+
+// closingBrace returns the index of the brace closing the brace at index
+// open, or -1 if it's not closed.
+func closingBrace(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isEnvNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isEnvNameChar(c byte) bool {
+	return isEnvNameStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,71 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviyaml
+
+import (
+	"errors"
+
+	"github.com/conduitio/evolviconf"
+	"github.com/conduitio/yaml/v3"
+)
+
+// positionError is an error about a field at a position in the parsed
+// document, see ErrorPosition. The message of err already contains the
+// position.
+type positionError struct {
+	pos evolviconf.Position
+	err error
+}
+
+// errorAt wraps err in an error positioned at node, which is found at path.
+func errorAt(node *yaml.Node, path []string, err error) error {
+	pos := evolviconf.Position{Line: node.Line, Column: node.Column}
+	if len(path) > 0 {
+		pos.Field = path[len(path)-1]
+	}
+	return &positionError{pos: pos, err: err}
+}
+
+func (e *positionError) Error() string { return e.err.Error() }
+func (e *positionError) Unwrap() error { return e.err }
+
+// ErrorPosition returns the position in the parsed document of an error
+// returned by the parser, e.g. a syntax error, a value that can't be decoded
+// or a secret that can't be resolved. The column is 0 if only the line is
+// known. It returns false if the error is not positioned.
+func ErrorPosition(err error) (evolviconf.Position, bool) {
+	var posErr *positionError
+	if errors.As(err, &posErr) {
+		return posErr.pos, true
+	}
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) && len(typeErr.Errors) > 0 {
+		// unknown fields are only reported as warnings, prefer the error that
+		// caused decoding to fail
+		uerr := typeErr.Errors[0]
+		for _, e := range typeErr.Errors {
+			if _, ok := e.(*yaml.UnknownFieldError); !ok {
+				uerr = e
+				break
+			}
+		}
+		return evolviconf.Position{Line: uerr.Line(), Column: uerr.Column()}, true
+	}
+	var parserErr *yaml.ParserError
+	if errors.As(err, &parserErr) && parserErr.Line > 0 {
+		return evolviconf.Position{Line: parserErr.Line}, true
+	}
+	return evolviconf.Position{}, false
+}
@@ -15,9 +15,13 @@
 package v1
 
 import (
+	"maps"
+	"slices"
+
 	"github.com/Masterminds/semver/v3"
 	"github.com/conduitio/evolviconf"
 	"github.com/conduitio/evolviconf/evolviyaml/example/yaml/model"
+	v2 "github.com/conduitio/evolviconf/evolviyaml/example/yaml/v2"
 )
 
 // Changelog should be adjusted every time we change the pipeline config and add
@@ -171,3 +175,139 @@ func (p DLQ) ToConfig() model.DLQ {
 		WindowNackThreshold: p.WindowNackThreshold,
 	}
 }
+
+// WithVersion returns the configuration with the supplied version, see
+// evolviconf.VersionSetter.
+func (c Configuration) WithVersion(version string) evolviconf.VersionedConfig[model.Configuration] {
+	c.Version = version
+	return c
+}
+
+// FromConfig populates the configuration from the config, see
+// evolviconf.ConfigFromer. The version is set by the parser with WithVersion,
+// fields that are lost in version 1.0 are reported by the parser.
+func (c *Configuration) FromConfig(cfg model.Configuration, _ *semver.Version) (evolviconf.Warnings, error) {
+	*c = Configuration{}
+	var warnings evolviconf.Warnings
+	if len(cfg.Pipelines) > 0 {
+		c.Pipelines = make(map[string]Pipeline, len(cfg.Pipelines))
+		for _, pipeline := range cfg.Pipelines {
+			p, w := pipelineFromConfig(pipeline)
+			c.Pipelines[pipeline.ID] = p
+			warnings = append(warnings, w...)
+		}
+	}
+	return warnings, nil
+}
+
+func pipelineFromConfig(p model.Pipeline) (Pipeline, evolviconf.Warnings) {
+	processors, warnings := processorsFromConfig(p.Processors)
+	pipeline := Pipeline{
+		Status:      p.Status,
+		Name:        p.Name,
+		Description: p.Description,
+		Processors:  processors,
+		DLQ: DLQ{
+			Plugin:              p.DLQ.Plugin,
+			Settings:            p.DLQ.Settings,
+			WindowSize:          p.DLQ.WindowSize,
+			WindowNackThreshold: p.DLQ.WindowNackThreshold,
+		},
+	}
+	if len(p.Connectors) > 0 {
+		pipeline.Connectors = make(map[string]Connector, len(p.Connectors))
+		for _, c := range p.Connectors {
+			processors, w := processorsFromConfig(c.Processors)
+			warnings = append(warnings, w...)
+			pipeline.Connectors[c.ID] = Connector{
+				Type:       c.Type,
+				Plugin:     c.Plugin,
+				Name:       c.Name,
+				Settings:   c.Settings,
+				Processors: processors,
+			}
+		}
+	}
+	return pipeline, warnings
+}
+
+func processorsFromConfig(processors []model.Processor) (map[string]Processor, evolviconf.Warnings) {
+	if len(processors) == 0 {
+		return nil, nil
+	}
+	var warnings evolviconf.Warnings
+	if len(processors) > 1 {
+		warnings = append(warnings, evolviconf.Warning{
+			Position: evolviconf.Position{Field: "processors"},
+			Message:  "the order of processors is not preserved in configuration files with version 1.x",
+			Code:     evolviconf.CodeFieldLost,
+		})
+	}
+	out := make(map[string]Processor, len(processors))
+	for _, p := range processors {
+		if p.Condition != "" {
+			warnings = append(warnings, evolviconf.Warning{
+				Position: evolviconf.Position{Field: "condition", Value: p.Condition},
+				Message:  "field condition of processor " + p.ID + " is not supported in version 1.x and is lost",
+				Code:     evolviconf.CodeFieldLost,
+			})
+		}
+		out[p.ID] = Processor{
+			// Type was removed in favor of Plugin
+			Type:     p.Plugin,
+			Settings: p.Settings,
+			Workers:  p.Workers,
+		}
+	}
+	return out, warnings
+}
+
+// Upgrade converts the configuration to version 2.x directly, see
+// evolviconf.Upgrader. Pipelines, connectors and processors are ordered by
+// their ID. The version is set by the parser with WithVersion.
+func (c Configuration) Upgrade() (evolviconf.VersionedConfig[model.Configuration], evolviconf.Warnings, error) {
+	var out v2.Configuration
+	for _, id := range slices.Sorted(maps.Keys(c.Pipelines)) {
+		p := c.Pipelines[id]
+		pipeline := v2.Pipeline{
+			ID:          id,
+			Status:      p.Status,
+			Name:        p.Name,
+			Description: p.Description,
+			Processors:  upgradeProcessors(p.Processors),
+			DLQ: v2.DLQ{
+				Plugin:              p.DLQ.Plugin,
+				Settings:            p.DLQ.Settings,
+				WindowSize:          p.DLQ.WindowSize,
+				WindowNackThreshold: p.DLQ.WindowNackThreshold,
+			},
+		}
+		for _, connID := range slices.Sorted(maps.Keys(p.Connectors)) {
+			conn := p.Connectors[connID]
+			pipeline.Connectors = append(pipeline.Connectors, v2.Connector{
+				ID:         connID,
+				Type:       conn.Type,
+				Plugin:     conn.Plugin,
+				Name:       conn.Name,
+				Settings:   conn.Settings,
+				Processors: upgradeProcessors(conn.Processors),
+			})
+		}
+		out.Pipelines = append(out.Pipelines, pipeline)
+	}
+	return out, nil, nil
+}
+
+func upgradeProcessors(processors map[string]Processor) []v2.Processor {
+	var out []v2.Processor
+	for _, id := range slices.Sorted(maps.Keys(processors)) {
+		p := processors[id]
+		out = append(out, v2.Processor{
+			ID:       id,
+			Plugin:   p.Type,
+			Settings: p.Settings,
+			Workers:  p.Workers,
+		})
+	}
+	return out
+}
@@ -15,6 +15,9 @@
 package v2
 
 import (
+	"context"
+	"strconv"
+
 	"github.com/Masterminds/semver/v3"
 	"github.com/conduitio/evolviconf"
 	"github.com/conduitio/evolviconf/evolviyaml/example/yaml/model"
@@ -22,8 +25,18 @@ import (
 
 // Changelog should be adjusted every time we change the pipeline config and add
 // a new config version. Based on the changelog the parser will output warnings.
+// The changes of fields annotated with evolvi tags (processors and the
+// dead letter queue window size) need to match the tags,
+// TestChangelogFromTags fails if they drift apart.
 var Changelog = evolviconf.Changelog{
-	semver.MustParse("2.0"): {}, // initial version
+	semver.MustParse("2.0"): { // initial version
+		{
+			Field:      "pipelines.*.dead-letter-queue.window-size",
+			ChangeType: evolviconf.DefaultChanged,
+			Message:    "the default of field window-size is 1 in version 2.0",
+			Default:    "1",
+		},
+	},
 	semver.MustParse("2.1"): {
 		{
 			Field:      "pipelines.*.processors.*.condition",
@@ -51,15 +64,32 @@ var Changelog = evolviconf.Changelog{
 			Field:      "pipelines.*.processors.*.type",
 			ChangeType: evolviconf.FieldDeprecated,
 			Message:    "please use field 'plugin' (introduced in version 2.2)",
+			ReplacedBy: "plugin",
 		},
 		{
 			Field:      "pipelines.*.connectors.*.processors.*.type",
 			ChangeType: evolviconf.FieldDeprecated,
 			Message:    "please use field 'plugin' (introduced in version 2.2)",
+			ReplacedBy: "plugin",
+		},
+		{
+			Field:      "pipelines.*.dead-letter-queue.window-size",
+			ChangeType: evolviconf.DefaultChanged,
+			Message:    "the default of field window-size changes to 4 in version 2.2",
+			Default:    "4",
 		},
 	},
 }
 
+var (
+	// conditionVersion is the version that introduces the field condition of
+	// processors.
+	conditionVersion = semver.MustParse("2.1")
+	// pluginVersion is the version that introduces the field plugin of
+	// processors and deprecates the field type.
+	pluginVersion = semver.MustParse("2.2")
+)
+
 type Configuration struct {
 	Version   string     `yaml:"version" json:"version"`
 	Pipelines []Pipeline `yaml:"pipelines" json:"pipelines"`
@@ -67,7 +97,7 @@ type Configuration struct {
 
 type Pipeline struct {
 	ID          string      `yaml:"id" json:"id"`
-	Status      string      `yaml:"status" json:"status"`
+	Status      string      `yaml:"status" json:"status" evolvi:"enum=running|stopped"`
 	Name        string      `yaml:"name" json:"name"`
 	Description string      `yaml:"description" json:"description"`
 	Connectors  []Connector `yaml:"connectors" json:"connectors"`
@@ -84,97 +114,138 @@ type Connector struct {
 	Processors []Processor       `yaml:"processors" json:"processors"`
 }
 
+// Processor is annotated with evolvi tags, ChangelogFromTags produces the same
+// processor changes as Changelog (see TestChangelogFromTags).
 type Processor struct {
 	ID string `yaml:"id" json:"id"`
 	// Deprecated: use Plugin instead.
-	Type      string            `yaml:"type" json:"type"`
-	Plugin    string            `yaml:"plugin" json:"plugin"`
-	Condition string            `yaml:"condition" json:"condition"`
+	Type      string            `yaml:"type" json:"type" evolvi:"deprecated=2.2,replaced-by=plugin,msg=please use field 'plugin' (introduced in version 2.2)"`
+	Plugin    string            `yaml:"plugin" json:"plugin" evolvi:"introduced=2.2,msg=field plugin was introduced in version 2.2, please update the pipeline config version"`
+	Condition string            `yaml:"condition" json:"condition" evolvi:"introduced=2.1,msg=field condition was introduced in version 2.1, please update the pipeline config version"`
 	Settings  map[string]string `yaml:"settings" json:"settings"`
 	Workers   int               `yaml:"workers" json:"workers"`
 }
 
+// DLQ is annotated with evolvi tags, the defaults of the window size need to
+// match the DefaultChanged changes in Changelog (see TestChangelogFromTags).
 type DLQ struct {
 	Plugin              string            `yaml:"plugin" json:"plugin"`
 	Settings            map[string]string `yaml:"settings" json:"settings"`
-	WindowSize          *int              `yaml:"window-size" json:"window-size"`
+	WindowSize          *int              `yaml:"window-size" json:"window-size" evolvi:"default@2.0=1,default@2.2=4"`
 	WindowNackThreshold *int              `yaml:"window-nack-threshold" json:"window-nack-threshold"`
 }
 
 func (c Configuration) ToConfig() (model.Configuration, error) {
+	cfg, _, err := c.ToConfigContext(context.Background())
+	return cfg, err
+}
+
+// ToConfigContext converts the configuration and returns warnings about
+// processors that fall back to the deprecated field type, see
+// evolviconf.ContextVersionedConfig. The field type is only deprecated in
+// version 2.2 and later, older versions don't produce warnings.
+func (c Configuration) ToConfigContext(ctx context.Context) (model.Configuration, evolviconf.Warnings, error) {
 	cfg := model.Configuration{Version: c.Version}
+	var warnings evolviconf.Warnings
 	if len(c.Pipelines) > 0 {
+		typeDeprecated := c.typeDeprecated()
 		cfg.Pipelines = make([]model.Pipeline, len(c.Pipelines))
 		for i, pipeline := range c.Pipelines {
-			cfg.Pipelines[i] = pipeline.ToConfig()
+			var w evolviconf.Warnings
+			cfg.Pipelines[i], w = pipeline.toConfig(evolviconf.ContextWithFieldPath(ctx, "pipelines", strconv.Itoa(i)), typeDeprecated)
+			warnings = append(warnings, w...)
 		}
 	}
-	return cfg, nil
+	return cfg, warnings, nil
+}
+
+// typeDeprecated returns true if the field type of processors is deprecated
+// in the version of the configuration.
+func (c Configuration) typeDeprecated() bool {
+	v, err := semver.NewVersion(c.Version)
+	return err == nil && !v.LessThan(pluginVersion)
 }
 
 func (p Pipeline) ToConfig() model.Pipeline {
+	cfg, _ := p.toConfig(context.Background(), false)
+	return cfg
+}
+
+func (p Pipeline) toConfig(ctx context.Context, typeDeprecated bool) (model.Pipeline, evolviconf.Warnings) {
+	connectors, warnings := p.connectorsToConfig(ctx, typeDeprecated)
+	processors, w := processorsToConfig(ctx, p.Processors, typeDeprecated)
 	return model.Pipeline{
 		ID:          p.ID,
 		Status:      p.Status,
 		Name:        p.Name,
 		Description: p.Description,
-		Connectors:  p.connectorsToConfig(),
-		Processors:  p.processorsToConfig(),
+		Connectors:  connectors,
+		Processors:  processors,
 		DLQ:         p.DLQ.ToConfig(),
-	}
+	}, append(warnings, w...)
 }
 
-func (p Pipeline) connectorsToConfig() []model.Connector {
+func (p Pipeline) connectorsToConfig(ctx context.Context, typeDeprecated bool) ([]model.Connector, evolviconf.Warnings) {
 	if len(p.Connectors) == 0 {
-		return nil
+		return nil, nil
 	}
+	var warnings evolviconf.Warnings
 	connectors := make([]model.Connector, len(p.Connectors))
 	for i, connector := range p.Connectors {
-		connectors[i] = connector.ToConfig()
+		var w evolviconf.Warnings
+		connectors[i], w = connector.toConfig(evolviconf.ContextWithFieldPath(ctx, "connectors", strconv.Itoa(i)), typeDeprecated)
+		warnings = append(warnings, w...)
 	}
-	return connectors
+	return connectors, warnings
 }
 
-func (p Pipeline) processorsToConfig() []model.Processor {
-	if len(p.Processors) == 0 {
-		return nil
-	}
-	processors := make([]model.Processor, len(p.Processors))
-
-	for i, processor := range p.Processors {
-		processors[i] = processor.ToConfig()
-	}
-	return processors
+func (c Connector) ToConfig() model.Connector {
+	cfg, _ := c.toConfig(context.Background(), false)
+	return cfg
 }
 
-func (c Connector) ToConfig() model.Connector {
+func (c Connector) toConfig(ctx context.Context, typeDeprecated bool) (model.Connector, evolviconf.Warnings) {
+	processors, warnings := processorsToConfig(ctx, c.Processors, typeDeprecated)
 	return model.Connector{
 		ID:         c.ID,
 		Type:       c.Type,
 		Plugin:     c.Plugin,
 		Name:       c.Name,
 		Settings:   c.Settings,
-		Processors: c.processorsToConfig(),
-	}
+		Processors: processors,
+	}, warnings
 }
 
-func (c Connector) processorsToConfig() []model.Processor {
-	if len(c.Processors) == 0 {
-		return nil
+func processorsToConfig(ctx context.Context, processors []Processor, typeDeprecated bool) ([]model.Processor, evolviconf.Warnings) {
+	if len(processors) == 0 {
+		return nil, nil
 	}
-	processors := make([]model.Processor, len(c.Processors))
-
-	for i, processor := range c.Processors {
-		processors[i] = processor.ToConfig()
+	var warnings evolviconf.Warnings
+	out := make([]model.Processor, len(processors))
+	for i, processor := range processors {
+		var w evolviconf.Warnings
+		out[i], w = processor.toConfig(evolviconf.ContextWithFieldPath(ctx, "processors", strconv.Itoa(i)), typeDeprecated)
+		warnings = append(warnings, w...)
 	}
-	return processors
+	return out, warnings
 }
 
 func (p Processor) ToConfig() model.Processor {
+	cfg, _ := p.toConfig(context.Background(), false)
+	return cfg
+}
+
+func (p Processor) toConfig(ctx context.Context, typeDeprecated bool) (model.Processor, evolviconf.Warnings) {
+	var warnings evolviconf.Warnings
 	plugin := p.Plugin
-	if plugin == "" {
-		// Fallback to deprecated field.
+	if plugin == "" && p.Type != "" {
+		// Fallback to deprecated field, type is the only field before 2.2.
 		plugin = p.Type
+		if typeDeprecated {
+			w := evolviconf.FieldWarning(ctx, "type", "processor "+p.ID+" has no plugin, using deprecated field type as plugin")
+			w.Code = evolviconf.CodeFieldDeprecated
+			warnings = append(warnings, w)
+		}
 	}
 
 	return model.Processor{
@@ -183,7 +254,7 @@ func (p Processor) ToConfig() model.Processor {
 		Settings:  p.Settings,
 		Workers:   p.Workers,
 		Condition: p.Condition,
-	}
+	}, warnings
 }
 
 func (p DLQ) ToConfig() model.DLQ {
@@ -194,3 +265,97 @@ func (p DLQ) ToConfig() model.DLQ {
 		WindowNackThreshold: p.WindowNackThreshold,
 	}
 }
+
+// WithVersion returns the configuration with the supplied version, see
+// evolviconf.VersionSetter.
+func (c Configuration) WithVersion(version string) evolviconf.VersionedConfig[model.Configuration] {
+	c.Version = version
+	return c
+}
+
+// FromConfig populates the configuration from the config for the supplied
+// version, see evolviconf.ConfigFromer. Before version 2.2 the plugin of
+// processors is stored in the field type, before version 2.1 conditions are
+// lost. The version is set by the parser with WithVersion.
+func (c *Configuration) FromConfig(cfg model.Configuration, version *semver.Version) (evolviconf.Warnings, error) {
+	*c = Configuration{}
+	var warnings evolviconf.Warnings
+	if len(cfg.Pipelines) > 0 {
+		c.Pipelines = make([]Pipeline, len(cfg.Pipelines))
+		for i, pipeline := range cfg.Pipelines {
+			var w evolviconf.Warnings
+			c.Pipelines[i], w = pipelineFromConfig(pipeline, version)
+			warnings = append(warnings, w...)
+		}
+	}
+	return warnings, nil
+}
+
+func pipelineFromConfig(p model.Pipeline, version *semver.Version) (Pipeline, evolviconf.Warnings) {
+	processors, warnings := processorsFromConfig(p.Processors, version)
+	pipeline := Pipeline{
+		ID:          p.ID,
+		Status:      p.Status,
+		Name:        p.Name,
+		Description: p.Description,
+		Processors:  processors,
+		DLQ:         dlqFromConfig(p.DLQ),
+	}
+	if len(p.Connectors) > 0 {
+		pipeline.Connectors = make([]Connector, len(p.Connectors))
+		for i, c := range p.Connectors {
+			processors, w := processorsFromConfig(c.Processors, version)
+			warnings = append(warnings, w...)
+			pipeline.Connectors[i] = Connector{
+				ID:         c.ID,
+				Type:       c.Type,
+				Plugin:     c.Plugin,
+				Name:       c.Name,
+				Settings:   c.Settings,
+				Processors: processors,
+			}
+		}
+	}
+	return pipeline, warnings
+}
+
+func processorsFromConfig(processors []model.Processor, version *semver.Version) ([]Processor, evolviconf.Warnings) {
+	if len(processors) == 0 {
+		return nil, nil
+	}
+	var warnings evolviconf.Warnings
+	out := make([]Processor, len(processors))
+	for i, p := range processors {
+		out[i] = Processor{
+			ID:       p.ID,
+			Plugin:   p.Plugin,
+			Settings: p.Settings,
+			Workers:  p.Workers,
+		}
+		if version.LessThan(pluginVersion) {
+			// plugin replaced type in 2.2
+			out[i].Type, out[i].Plugin = p.Plugin, ""
+		}
+		switch {
+		case p.Condition == "":
+		case version.LessThan(conditionVersion):
+			warnings = append(warnings, evolviconf.Warning{
+				Position: evolviconf.Position{Field: "condition", Value: p.Condition},
+				Message:  "field condition of processor " + p.ID + " is not supported in version " + version.Original() + " and is lost",
+				Code:     evolviconf.CodeFieldLost,
+			})
+		default:
+			out[i].Condition = p.Condition
+		}
+	}
+	return out, warnings
+}
+
+func dlqFromConfig(d model.DLQ) DLQ {
+	return DLQ{
+		Plugin:              d.Plugin,
+		Settings:            d.Settings,
+		WindowSize:          d.WindowSize,
+		WindowNackThreshold: d.WindowNackThreshold,
+	}
+}
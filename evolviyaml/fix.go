@@ -0,0 +1,142 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviyaml
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/conduitio/evolviconf"
+	"github.com/conduitio/yaml/v3"
+)
+
+// ApplyFixes applies the fixes of all warnings to src and returns the fixed
+// source. Columns in the ranges of edits count runes, not bytes. Warnings without a fix and warnings in other files (see
+// evolviconf.Position.File) are ignored. It returns an error if edits
+// overlap or point outside of src, in that case src is not modified.
+func ApplyFixes(src []byte, warnings evolviconf.Warnings) ([]byte, error) {
+	type edit struct {
+		start, end int
+		text       string
+	}
+
+	lineStarts := []int{0}
+	for i, b := range src {
+		if b == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	// offset converts a one-based line and column into a byte offset, columns
+	// count runes like the positions reported by the YAML parser
+	offset := func(line, column int) (int, error) {
+		if line < 1 || line > len(lineStarts) || column < 1 {
+			return 0, fmt.Errorf("position %d:%d is out of range", line, column)
+		}
+		off, end := lineStarts[line-1], len(src)
+		if line < len(lineStarts) {
+			end = lineStarts[line] - 1 // newline
+		}
+		for range column - 1 {
+			if off >= end {
+				return 0, fmt.Errorf("position %d:%d is out of range", line, column)
+			}
+			_, size := utf8.DecodeRune(src[off:])
+			off += size
+		}
+		return off, nil
+	}
+
+	var edits []edit
+	for _, w := range warnings {
+		if w.Fix == nil || w.File != "" {
+			continue
+		}
+		for _, e := range w.Fix.Edits {
+			start, err := offset(e.Range.StartLine, e.Range.StartColumn)
+			if err != nil {
+				return nil, err
+			}
+			end, err := offset(e.Range.EndLine, e.Range.EndColumn)
+			if err != nil {
+				return nil, err
+			}
+			if end < start {
+				return nil, fmt.Errorf("invalid range %d:%d-%d:%d", e.Range.StartLine, e.Range.StartColumn, e.Range.EndLine, e.Range.EndColumn)
+			}
+			edits = append(edits, edit{start: start, end: end, text: e.NewText})
+		}
+	}
+
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].start < edits[j].start
+	})
+	for i := 1; i < len(edits); i++ {
+		if edits[i].start < edits[i-1].end {
+			return nil, fmt.Errorf("overlapping edits at offset %d", edits[i].start)
+		}
+	}
+
+	var out bytes.Buffer
+	last := 0
+	for _, e := range edits {
+		out.Write(src[last:e.start])
+		out.WriteString(e.text)
+		last = e.end
+	}
+	out.Write(src[last:])
+	return out.Bytes(), nil
+}
+
+// FixFile parses the file with the parser, applies the fixes of all returned
+// warnings (see ApplyFixes) and writes the result back to the file. It
+// returns the warnings that were fixed, fixes in included files are not
+// applied.
+func FixFile[T any](ctx context.Context, parser *evolviconf.Parser[T, *yaml.Decoder], name string) (evolviconf.Warnings, error) {
+	src, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	_, warnings, err := parser.Parse(evolviconf.ContextWithSourceName(ctx, name), bytes.NewReader(src))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse file: %w", err)
+	}
+
+	var fixed evolviconf.Warnings
+	for _, w := range warnings {
+		if w.Fix != nil && w.File == "" {
+			fixed = append(fixed, w)
+		}
+	}
+	if len(fixed) == 0 {
+		return nil, nil
+	}
+
+	out, err := ApplyFixes(src, fixed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply fixes: %w", err)
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	if err := os.WriteFile(name, out, info.Mode().Perm()); err != nil {
+		return nil, fmt.Errorf("failed to write file: %w", err)
+	}
+	return fixed, nil
+}
@@ -1,12 +1,11 @@
 module github.com/conduitio/evolviconf/evolviyaml
 
-go 1.23.0
+go 1.24.2
 
 require (
-	github.com/Masterminds/semver/v3 v3.3.0
+	github.com/Masterminds/semver/v3 v3.4.0
+	github.com/conduitio/evolviconf v0.0.0-20241105144321-27c16bddeb38
 	github.com/conduitio/yaml/v3 v3.3.0
 	github.com/google/go-cmp v0.6.0
 	github.com/matryer/is v1.4.1
 )
-
-require github.com/conduitio/evolviconf v0.0.0-20241105144321-27c16bddeb38 // indirect
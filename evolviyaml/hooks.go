@@ -22,7 +22,8 @@ import (
 )
 
 // EnvDecoderHook replaces all string values with their environment variable
-// expanded versions.
+// expanded versions. Unset variables are silently replaced with an empty
+// string, see EnvExpander for defaults and warnings about unset variables.
 func EnvDecoderHook(_ []string, node *yaml.Node) {
 	if node.Kind == yaml.ScalarNode && node.Tag == "!!str" {
 		node.SetString(os.ExpandEnv(node.Value))
@@ -0,0 +1,140 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviyaml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/conduitio/evolviconf"
+	"github.com/conduitio/yaml/v3"
+)
+
+// IncludeTag is the YAML tag that replaces a scalar with the contents of
+// another file, the value of the scalar is the path of the file (e.g.
+// `connector: !include connectors/s3.yml`).
+const IncludeTag = "!include"
+
+// ErrIncludeCycle is returned if a file includes itself, directly or through
+// other files.
+var ErrIncludeCycle = errors.New("include cycle")
+
+// includer replaces scalars tagged with IncludeTag with the documents read
+// from fsys while a document is decoded. The nodes of included documents go
+// through the hooks in next, positions and warnings created for them are
+// marked with the included file.
+type includer struct {
+	fsys fs.FS
+	// next contains the hooks that run for every node after includes are
+	// resolved.
+	next      yaml.DecoderHook
+	warn      *evolviconf.Warnings
+	positions evolviconf.FieldPositions
+	// stack contains the files that are currently being included, the last
+	// file is the innermost one.
+	stack []string
+	errs  []error
+}
+
+// hook resolves includes and runs the next hooks.
+func (in *includer) hook(fieldPath []string, node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && node.Tag == IncludeTag {
+		if err := in.include(fieldPath, node); err != nil {
+			in.errs = append(in.errs, errorAt(node, fieldPath, fmt.Errorf("%d:%d: field %s: failed to include %q: %w", node.Line, node.Column, strings.Join(fieldPath, "."), node.Value, err)))
+		}
+	}
+	if in.next != nil {
+		in.next(fieldPath, node)
+	}
+}
+
+// include replaces node with the document in the file referenced by node.
+// Paths in the parsed document are relative to the root of fsys, paths in
+// included files are relative to the directory of the including file.
+func (in *includer) include(fieldPath []string, node *yaml.Node) error {
+	name := node.Value
+	if len(in.stack) > 0 {
+		name = path.Join(path.Dir(in.stack[len(in.stack)-1]), name)
+	}
+	name = path.Clean(name)
+	if !fs.ValidPath(name) {
+		return fmt.Errorf("invalid path %q", name)
+	}
+	if slices.Contains(in.stack, name) {
+		return fmt.Errorf("%w: %s", ErrIncludeCycle, strings.Join(append(slices.Clone(in.stack), name), " -> "))
+	}
+
+	b, err := fs.ReadFile(in.fsys, name)
+	if err != nil {
+		return err
+	}
+
+	in.stack = append(in.stack, name)
+	defer func() { in.stack = in.stack[:len(in.stack)-1] }()
+
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.WithHook(func(sub []string, n *yaml.Node) {
+		full := append(slices.Clip(fieldPath), sub...)
+		before := len(*in.warn)
+		in.hook(full, n)
+		in.markFile(name, full, before)
+	})
+
+	var doc yaml.Node
+	err = dec.Decode(&doc)
+	if errors.Is(err, io.EOF) || (err == nil && len(doc.Content) == 0) {
+		// empty file
+		doc.Content = []*yaml.Node{{Kind: yaml.ScalarNode, Tag: "!!null"}}
+	} else if err != nil {
+		return err
+	}
+
+	// keep the position of the include, so that the field is positioned in
+	// the including file
+	line, column := node.Line, node.Column
+	*node = *doc.Content[0]
+	node.Line, node.Column = line, column
+	return nil
+}
+
+// markFile sets the file of the warnings appended since index before and of
+// the position recorded at path, unless they already belong to a file (e.g.
+// a nested include).
+func (in *includer) markFile(name string, fieldPath []string, before int) {
+	for i := before; i < len(*in.warn); i++ {
+		if (*in.warn)[i].File == "" {
+			(*in.warn)[i].File = name
+		}
+	}
+	if in.positions == nil {
+		return
+	}
+	key := strings.Join(fieldPath, ".")
+	if pos, ok := in.positions[key]; ok && pos.File == "" {
+		pos.File = name
+		in.positions[key] = pos
+	}
+}
+
+// err returns the errors that occurred while resolving includes.
+func (in *includer) err() error {
+	return errors.Join(in.errs...)
+}
@@ -0,0 +1,102 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviyaml
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/conduitio/evolviconf"
+	"github.com/conduitio/yaml/v3"
+)
+
+// KindVersionParser reads the kind and version of YAML documents. It is used
+// with evolviconf.Mux to parse YAML streams containing documents of different
+// kinds, like Kubernetes manifests:
+//
+//	mux := evolviconf.NewMux(evolviyaml.NewKindVersionParser(), evolviyaml.NewKindVersionParser())
+//	evolviconf.RegisterKind(mux, "Pipeline", pipelineV1Parser, pipelineV2Parser)
+//	evolviconf.RegisterKind(mux, "Connector", connectorV1Parser)
+type KindVersionParser struct {
+	kindKey       []string
+	versionKey    []string
+	versionScheme evolviconf.VersionScheme
+}
+
+// NewKindVersionParser returns a parser that reads the kind from the
+// top-level key "kind" and the version from the top-level key "version".
+func NewKindVersionParser() *KindVersionParser {
+	return &KindVersionParser{
+		kindKey:       []string{"kind"},
+		versionKey:    []string{"version"},
+		versionScheme: evolviconf.SemverScheme,
+	}
+}
+
+// WithKindKey sets the path to the field containing the kind of the
+// document, nested keys are separated by dots.
+func (p *KindVersionParser) WithKindKey(path string) *KindVersionParser {
+	p.kindKey = strings.Split(path, ".")
+	return p
+}
+
+// WithVersionKey sets the path to the field containing the version of the
+// document, nested keys are separated by dots (e.g. "apiVersion").
+func (p *KindVersionParser) WithVersionKey(path string) *KindVersionParser {
+	p.versionKey = strings.Split(path, ".")
+	return p
+}
+
+// SetVersionScheme sets the scheme used to parse versions, see
+// evolviconf.VersionSchemeSetter. It is called by
+// evolviconf.Mux.WithVersionScheme, the default is evolviconf.SemverScheme.
+func (p *KindVersionParser) SetVersionScheme(scheme evolviconf.VersionScheme) {
+	p.versionScheme = scheme
+}
+
+func (p *KindVersionParser) Decoder(reader io.Reader) *yaml.Decoder {
+	return yaml.NewDecoder(reader)
+}
+
+// ParseKindVersion parses the kind and version of the next document. The
+// returned version is nil if the document doesn't contain a version.
+func (p *KindVersionParser) ParseKindVersion(_ context.Context, dec *yaml.Decoder) (string, *semver.Version, error) {
+	var doc yaml.Node
+	err := dec.Decode(&doc)
+	if err != nil {
+		return "", nil, err
+	}
+
+	kind := lookupNode(&doc, p.kindKey)
+	if kind != nil && kind.Kind != yaml.ScalarNode {
+		return "", nil, errorAt(kind, p.kindKey, fmt.Errorf("line %d: field %s must be a scalar", kind.Line, strings.Join(p.kindKey, ".")))
+	}
+	if kind == nil || kind.Value == "" {
+		return "", nil, evolviconf.ErrKindNotSpecified
+	}
+
+	version, err := versionFromNode(&doc, p.versionKey, p.versionScheme)
+	if err != nil {
+		if errors.Is(err, evolviconf.ErrVersionNotSpecified) {
+			return kind.Value, nil, nil
+		}
+		return "", nil, err
+	}
+	return kind.Value, version, nil
+}
@@ -15,89 +15,120 @@
 package evolviyaml
 
 import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
 	"github.com/Masterminds/semver/v3"
 	"github.com/conduitio/evolviconf"
 	"github.com/conduitio/yaml/v3"
 )
 
 type configLinter struct {
-	// expandedChangelog contains a map of all changes in the changelog. The
-	// first key is the version, the second is a map of changes in that version.
-	// Changes are stored hierarchical in submaps. For example, if the field
-	// x.y.z changed in version 1.2.3 the map will contain
-	// { "1.2.3" : { "x" : { "y" : { "z" : Change{} } } } }.
-	expandedChangelog map[*semver.Version]map[string]any
+	// changelog contains all changes and precomputes the changes that apply to
+	// each version. Changes are stored hierarchical in submaps. For example,
+	// if the field x.y.z changed in version 1.2.3 the rules for version 1.2.3
+	// contain { "x" : { "y" : { "z" : Change{} } } }.
+	changelog *evolviconf.ChangelogIndex
+	// redactor redacts the values of sensitive fields in warnings.
+	redactor *evolviconf.Redactor
 }
 
-func newConfigLinter(changelog evolviconf.Changelog) *configLinter {
+func newConfigLinter(changelog *evolviconf.ChangelogIndex, redactor *evolviconf.Redactor) *configLinter {
 	return &configLinter{
-		expandedChangelog: changelog.Expand(),
+		changelog: changelog,
+		redactor:  redactor,
 	}
 }
 
 func (cl *configLinter) DecoderHook(version *semver.Version, warn *evolviconf.Warnings) yaml.DecoderHook {
+	// look up the rules once per document, so inspecting a node does not
+	// depend on the size of the changelog
+	rules := cl.changelog.Rules(version)
+	// fixes that rename a field are dropped if the mapping already contains
+	// the replacement, otherwise the fixed document would contain a
+	// duplicate key; seen contains the paths of all fields and pending the
+	// indices of warnings with a rename fix keyed by the replacement path
+	seen := make(map[string]bool)
+	pending := make(map[string][]int)
 	return func(path []string, node *yaml.Node) {
-		if w, ok := cl.InspectNode(version, path, node); ok {
-			*warn = append(*warn, w)
+		key := strings.Join(path, ".")
+		seen[key] = true
+		for _, i := range pending[key] {
+			(*warn)[i].Fix = nil
 		}
-	}
-}
+		delete(pending, key)
 
-func (cl *configLinter) InspectNode(version *semver.Version, path []string, node *yaml.Node) (evolviconf.Warning, bool) {
-	if c, ok := cl.findChange(version, path); ok {
-		return cl.newWarning(path[len(path)-1], node, c.Message), true
-	}
-	return evolviconf.Warning{}, false
-}
-
-func (cl *configLinter) findChange(version *semver.Version, path []string) (evolviconf.Change, bool) {
-	curMap := cl.changelogForVersion(version)
-	last := len(path) - 1
-	for i, field := range path {
-		nextMap, ok := curMap[field]
+		w, ok := cl.InspectNode(rules, path, node)
 		if !ok {
-			nextMap, ok = curMap["*"]
-			if !ok {
-				break
-			}
+			return
 		}
-		switch v := nextMap.(type) {
-		case map[string]any:
-			curMap = v
-			continue
-		case evolviconf.Change:
-			if i == last {
-				return v, true
+		if c, _ := evolviconf.FindChange(rules, path); w.Fix != nil && c.ReplacedBy != "" {
+			replacement := strings.Join(append(slices.Clone(path[:len(path)-1]), c.ReplacedBy), ".")
+			if seen[replacement] {
+				w.Fix = nil
+			} else {
+				pending[replacement] = append(pending[replacement], len(*warn))
 			}
 		}
-		break
+		*warn = append(*warn, w)
 	}
-	return evolviconf.Change{}, false
 }
 
-func (cl *configLinter) changelogForVersion(version *semver.Version) map[string]any {
-	var bestMatch *semver.Version
-	for v, m := range cl.expandedChangelog {
-		if version.Equal(v) {
-			// Perfect match.
-			return m
-		}
-		if version.GreaterThan(v) && (bestMatch == nil || v.GreaterThan(bestMatch)) {
-			// Store the best match so far, in case we don't find a perfect match.
-			bestMatch = v
-		}
+func (cl *configLinter) InspectNode(rules map[string]any, path []string, node *yaml.Node) (evolviconf.Warning, bool) {
+	if c, ok := evolviconf.FindChange(rules, path); ok {
+		w := cl.newWarning(path, node, c.Message)
+		w.Code = c.ChangeType.WarningCode()
+		w.Fix = cl.newFix(w.Position, c)
+		return w, true
 	}
-	return cl.expandedChangelog[bestMatch]
+	return evolviconf.Warning{}, false
 }
 
-func (cl *configLinter) newWarning(field string, node *yaml.Node, message string) evolviconf.Warning {
-	return evolviconf.Warning{
+// newWarning returns a warning about the field at path, the value of the node
+// is redacted if the field is sensitive.
+func (cl *configLinter) newWarning(path []string, node *yaml.Node, message string) evolviconf.Warning {
+	return cl.redactor.RedactWarning(evolviconf.Warning{
 		Position: evolviconf.Position{
-			Field:  field,
+			Field:  path[len(path)-1],
 			Line:   node.Line,
 			Column: node.Column,
 			Value:  node.Value,
 		},
 		Message: message,
+	}, path)
+}
+
+// redactPositions redacts the values of sensitive fields in positions, so
+// they don't leak into warnings created during the conversion.
+func (cl *configLinter) redactPositions(positions evolviconf.FieldPositions) {
+	for k, pos := range positions {
+		if pos.Value != "" && cl.redactor.Sensitive(strings.Split(k, ".")) {
+			pos.Value = evolviconf.RedactedValue
+			positions[k] = pos
+		}
+	}
+}
+
+// newFix returns a fix for the warning about the change, or nil if the change
+// can't be fixed automatically. Deprecated fields that are replaced by another
+// field are fixed by renaming the key, unless the mapping already contains the
+// replacement (see DecoderHook).
+func (cl *configLinter) newFix(pos evolviconf.Position, change evolviconf.Change) *evolviconf.Fix {
+	if change.ChangeType != evolviconf.FieldDeprecated || change.ReplacedBy == "" || pos.Line == 0 {
+		return nil
+	}
+	return &evolviconf.Fix{
+		Message: fmt.Sprintf("rename field %s to %s", pos.Field, change.ReplacedBy),
+		Edits: []evolviconf.TextEdit{{
+			Range: evolviconf.Range{
+				StartLine:   pos.Line,
+				StartColumn: pos.Column,
+				EndLine:     pos.Line,
+				EndColumn:   pos.Column + utf8.RuneCountInString(pos.Field),
+			},
+			NewText: change.ReplacedBy,
+		}},
 	}
 }
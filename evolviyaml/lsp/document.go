@@ -0,0 +1,252 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lsp
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/conduitio/evolviconf"
+	"github.com/conduitio/yaml/v3"
+)
+
+// document is the text of an open file split into lines. The path helpers
+// work on the text instead of the parsed YAML tree, because documents are
+// usually not valid YAML while the user is typing. Characters in positions
+// passed to and returned by the helpers count UTF-16 code units like LSP
+// positions, lines and columns reported by the YAML parser count runes.
+type document struct {
+	text  string
+	lines []string
+	// nodes are the parsed YAML documents, they are used to compute the
+	// ranges of diagnostics. Documents that can't be parsed are omitted.
+	nodes []*yaml.Node
+	// warnings are the warnings returned when the document was parsed.
+	warnings evolviconf.Warnings
+}
+
+func newDocument(text string) document {
+	d := document{
+		text:  text,
+		lines: strings.Split(text, "\n"),
+	}
+	dec := yaml.NewDecoder(strings.NewReader(text))
+	for {
+		var node yaml.Node
+		err := dec.Decode(&node)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			break // the rest of the stream can't be parsed
+		}
+		d.nodes = append(d.nodes, &node)
+	}
+	return d
+}
+
+// rangeAt returns the range of the YAML node at the one-based line and
+// column. If a mapping key named field starts at the position, the range
+// covers the key. Otherwise it covers the outermost node starting at the
+// position, scalars are covered completely, collections (e.g. a sequence item
+// that is the closest parent of a missing field) up to the end of the line.
+func (d document) rangeAt(line, column int, field string) Range {
+	start := Position{Line: max(line-1, 0), Character: d.character(line, column)}
+	end := Position{Line: start.Line, Character: start.Character}
+	if line-1 < len(d.lines) {
+		end.Character = max(start.Character, utf16Len(strings.TrimRight(d.lines[start.Line], " \t\r")))
+	}
+
+	node := d.nodeAt(line, column, field)
+	if node != nil && node.Kind == yaml.ScalarNode && node.Style == 0 && !strings.Contains(node.Value, "\n") {
+		end.Character = start.Character + utf16Len(node.Value)
+	}
+	return Range{Start: start, End: end}
+}
+
+// character converts the one-based line and rune column reported by the YAML
+// parser into a zero-based character counting UTF-16 code units.
+func (d document) character(line, column int) int {
+	if column < 1 {
+		return 0
+	}
+	if line < 1 || line > len(d.lines) {
+		return column - 1
+	}
+	n := 0
+	for _, r := range d.lines[line-1] {
+		if column == 1 {
+			return n
+		}
+		column--
+		n += utf16.RuneLen(r)
+	}
+	return n + column - 1 // past the end of the line
+}
+
+// nodeAt returns the node starting at the one-based line and column. A
+// mapping key named field is preferred, otherwise the outermost node is
+// returned. It returns nil if no node starts at the position.
+func (d document) nodeAt(line, column int, field string) *yaml.Node {
+	var found []*yaml.Node
+	var keys []*yaml.Node
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		if n.Line == line && n.Column == column {
+			found = append(found, n)
+		}
+		if n.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if k := n.Content[i]; k.Line == line && k.Column == column && k.Value == field {
+					keys = append(keys, k)
+				}
+			}
+		}
+		if n.Kind == yaml.AliasNode {
+			return
+		}
+		for _, c := range n.Content {
+			walk(c)
+		}
+	}
+	for _, n := range d.nodes {
+		for _, c := range n.Content {
+			walk(c)
+		}
+	}
+	switch {
+	case len(keys) > 0:
+		return keys[0]
+	case len(found) > 0:
+		return found[0]
+	default:
+		return nil
+	}
+}
+
+// yamlDocumentAt returns the text of the YAML document (part of the multi
+// document stream separated by "---") that contains line.
+func (d document) yamlDocumentAt(line int) string {
+	start, end := 0, len(d.lines)
+	for i, l := range d.lines {
+		if !strings.HasPrefix(l, "---") {
+			continue
+		}
+		if i <= line {
+			start = i + 1
+		} else {
+			end = i
+			break
+		}
+	}
+	return strings.Join(d.lines[start:end], "\n")
+}
+
+// keyAt returns the key on line and the character where it starts if the
+// character col points into the key.
+func (d document) keyAt(line, col int) (string, int, bool) {
+	if line < 0 || line >= len(d.lines) {
+		return "", 0, false
+	}
+	l := d.lines[line]
+	col = byteOffset(l, col)
+	start := indentation(l)
+	if strings.HasPrefix(l[start:], "- ") {
+		start += 2 + indentation(l[start+2:])
+	}
+	end := strings.Index(l[start:], ":")
+	if end <= 0 {
+		return "", 0, false
+	}
+	end += start
+	if col < start || col > end {
+		return "", 0, false
+	}
+	return strings.TrimSpace(l[start:end]), utf16Len(l[:start]), true
+}
+
+// pathAt returns the path of keys leading to the mapping that contains a key
+// at line and the character col. Sequence items are represented with a
+// wildcard ("*").
+func (d document) pathAt(line, col int) []string {
+	var path []string
+	indent := col
+	if line >= 0 && line < len(d.lines) {
+		indent = byteOffset(d.lines[line], col)
+		prefix := d.lines[line][:min(indent, len(d.lines[line]))]
+		if trimmed := strings.TrimSpace(prefix); trimmed == "-" {
+			// the key is the first key in a sequence item
+			path = append(path, "*")
+			indent = strings.Index(prefix, "-")
+		}
+	}
+
+	for i := line - 1; i >= 0 && indent > 0; i-- {
+		l := d.lines[i]
+		trimmed := strings.TrimSpace(l)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(l, "---") {
+			continue
+		}
+		lineIndent := indentation(l)
+		if lineIndent >= indent {
+			continue
+		}
+
+		content := l[lineIndent:]
+		if strings.HasPrefix(content, "- ") {
+			// we are inside of a sequence item, the key on the same line as
+			// the dash is a sibling
+			path = append(path, "*")
+			indent = lineIndent
+			continue
+		}
+		if key, _, ok := strings.Cut(content, ":"); ok {
+			path = append(path, strings.TrimSpace(key))
+		}
+		indent = lineIndent
+	}
+
+	slices.Reverse(path)
+	return path
+}
+
+func indentation(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// byteOffset converts the character col counting UTF-16 code units into a
+// byte offset in line. Characters past the end of the line count one byte.
+func byteOffset(line string, col int) int {
+	n := 0
+	for i, r := range line {
+		if n >= col {
+			return i
+		}
+		n += utf16.RuneLen(r)
+	}
+	return len(line) + max(col-n, 0)
+}
+
+// utf16Len returns the number of UTF-16 code units needed to encode s.
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
@@ -0,0 +1,431 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lsp contains a language server for versioned YAML configuration
+// files. It publishes the warnings produced by an evolviconf.Parser as
+// diagnostics, shows change messages from the changelog on hover and
+// completes field names that are valid for the version of the document.
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/conduitio/evolviconf"
+	"github.com/conduitio/evolviconf/evolviyaml"
+	"github.com/conduitio/yaml/v3"
+)
+
+// Schema describes the fields and changes of versioned configurations that
+// match Constraint. It is implemented by *evolviyaml.Parser. Schemas that
+// implement evolviconf.PrereleaseAcceptor are matched against pre-release
+// versions like evolviconf.Parser does.
+type Schema interface {
+	Constraint() evolviconf.VersionConstraint
+	LatestKnownVersion() *semver.Version
+	ParseVersion(ctx context.Context, dec *yaml.Decoder) (*semver.Version, error)
+	FindChange(version *semver.Version, path []string) (evolviconf.Change, bool)
+	FieldNames(version *semver.Version, path []string) []string
+}
+
+// Server is a language server for versioned YAML configuration files. It
+// only supports full document synchronization.
+type Server[T any] struct {
+	name    string
+	parser  *evolviconf.Parser[T, *yaml.Decoder]
+	schemas []Schema
+
+	conn     *conn
+	docs     map[string]document
+	shutdown bool
+}
+
+// NewServer creates a language server that parses documents with parser and
+// uses schemas for hover and completion. Schemas are usually the same
+// *evolviyaml.Parser instances used to construct parser.
+func NewServer[T any](parser *evolviconf.Parser[T, *yaml.Decoder], schemas ...Schema) *Server[T] {
+	return &Server[T]{
+		name:    "evolviconf",
+		parser:  parser,
+		schemas: schemas,
+		docs:    make(map[string]document),
+	}
+}
+
+// WithName sets the name of the server, which is reported to the client and
+// used as the source of diagnostics.
+func (s *Server[T]) WithName(name string) *Server[T] {
+	s.name = name
+	return s
+}
+
+// Serve reads requests from r and writes responses and notifications to w
+// (e.g. os.Stdin and os.Stdout). It returns when the client sends the exit
+// notification, the reader is closed or the context is cancelled. Messages are
+// read in a separate goroutine, if the context is cancelled while it waits for
+// input, r is closed if it implements io.Closer to stop the goroutine.
+func (s *Server[T]) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.conn = newConn(r, w)
+
+	type readResult struct {
+		msg message
+		err error
+	}
+	results := make(chan readResult)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			msg, err := s.conn.read()
+			select {
+			case results <- readResult{msg: msg, err: err}:
+			case <-done:
+				return
+			}
+			var rerr *responseError
+			if err != nil && !errors.As(err, &rerr) {
+				return
+			}
+		}
+	}()
+
+	for {
+		var res readResult
+		select {
+		case <-ctx.Done():
+			if c, ok := r.(io.Closer); ok {
+				_ = c.Close()
+			}
+			return ctx.Err()
+		case res = <-results:
+		}
+
+		msg, err := res.msg, res.err
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			var rerr *responseError
+			if errors.As(err, &rerr) {
+				// malformed message, report it and continue
+				if err := s.conn.reply(nil, nil, rerr); err != nil {
+					return err
+				}
+				continue
+			}
+			return err
+		}
+
+		if msg.Method == "exit" {
+			return nil
+		}
+
+		result, err := s.handle(ctx, msg)
+		if msg.ID == nil {
+			// notifications don't get a response
+			continue
+		}
+		if err := s.conn.reply(msg.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server[T]) handle(ctx context.Context, msg message) (any, error) {
+	if s.shutdown && msg.ID != nil {
+		return nil, &responseError{Code: codeInvalidRequest, Message: "server is shutting down"}
+	}
+
+	switch msg.Method {
+	case "initialize":
+		return InitializeResult{
+			Capabilities: ServerCapabilities{
+				TextDocumentSync:   textDocumentSyncFull,
+				HoverProvider:      true,
+				CompletionProvider: &CompletionOptions{},
+				CodeActionProvider: true,
+			},
+			ServerInfo: &ServerInfo{Name: s.name},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := unmarshalParams(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.update(ctx, params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := unmarshalParams(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		// full sync, the last change contains the whole document
+		text := params.ContentChanges[len(params.ContentChanges)-1].Text
+		return nil, s.update(ctx, params.TextDocument.URI, text)
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := unmarshalParams(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []Diagnostic{},
+		})
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		if err := unmarshalParams(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.hover(ctx, params), nil
+	case "textDocument/completion":
+		var params TextDocumentPositionParams
+		if err := unmarshalParams(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.completion(ctx, params), nil
+	case "textDocument/codeAction":
+		var params CodeActionParams
+		if err := unmarshalParams(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.codeActions(params), nil
+	case "initialized", "$/cancelRequest", "$/setTrace":
+		return nil, nil
+	default:
+		if msg.ID == nil {
+			return nil, nil // ignore unknown notifications
+		}
+		return nil, &responseError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method}
+	}
+}
+
+// update parses and stores the document and publishes its diagnostics.
+func (s *Server[T]) update(ctx context.Context, uri, text string) error {
+	doc := newDocument(text)
+	var diagnostics []Diagnostic
+	doc.warnings, diagnostics = s.diagnostics(ctx, doc)
+	s.docs[uri] = doc
+	return s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: diagnostics,
+	})
+}
+
+func (s *Server[T]) diagnostics(ctx context.Context, doc document) (evolviconf.Warnings, []Diagnostic) {
+	_, warnings, err := s.parser.Parse(ctx, strings.NewReader(doc.text))
+	if err != nil {
+		var r Range // errors without a position are shown on the first line
+		if pos, ok := evolviyaml.ErrorPosition(err); ok {
+			r = doc.rangeAt(pos.Line, pos.Column, pos.Field)
+		}
+		return nil, []Diagnostic{{
+			Range:    r,
+			Severity: SeverityError,
+			Source:   s.name,
+			Message:  err.Error(),
+		}}
+	}
+
+	// warnings in included files can't be positioned in the document
+	warnings = slices.DeleteFunc(warnings, func(w evolviconf.Warning) bool {
+		return w.File != ""
+	})
+	diagnostics := make([]Diagnostic, len(warnings))
+	for i, w := range warnings {
+		diagnostics[i] = s.diagnostic(doc, w)
+	}
+	return warnings, diagnostics
+}
+
+// diagnostic converts the warning into a diagnostic, the range is computed
+// from the YAML node at the position of the warning. The warning must be
+// positioned in the document, not in an included file.
+func (s *Server[T]) diagnostic(doc document, w evolviconf.Warning) Diagnostic {
+	severity := SeverityWarning
+	if w.Severity == evolviconf.SeverityError {
+		severity = SeverityError
+	}
+	return Diagnostic{
+		Range:    doc.rangeAt(w.Line, w.Column, w.Field),
+		Severity: severity,
+		Source:   s.name,
+		Message:  w.Message,
+	}
+}
+
+// codeActions returns quick fixes for all warnings with a fix in the
+// requested range.
+func (s *Server[T]) codeActions(params CodeActionParams) []CodeAction {
+	actions := []CodeAction{}
+	doc, ok := s.docs[params.TextDocument.URI]
+	if !ok {
+		return actions
+	}
+
+	for _, w := range doc.warnings {
+		if w.Fix == nil {
+			continue
+		}
+		d := s.diagnostic(doc, w)
+		if d.Range.End.Line < params.Range.Start.Line || d.Range.Start.Line > params.Range.End.Line {
+			continue
+		}
+
+		edits := make([]TextEdit, len(w.Fix.Edits))
+		for i, e := range w.Fix.Edits {
+			edits[i] = TextEdit{
+				Range: Range{
+					Start: Position{Line: e.Range.StartLine - 1, Character: doc.character(e.Range.StartLine, e.Range.StartColumn)},
+					End:   Position{Line: e.Range.EndLine - 1, Character: doc.character(e.Range.EndLine, e.Range.EndColumn)},
+				},
+				NewText: e.NewText,
+			}
+		}
+		actions = append(actions, CodeAction{
+			Title:       w.Fix.Message,
+			Kind:        CodeActionKindQuickFix,
+			Diagnostics: []Diagnostic{d},
+			Edit: &WorkspaceEdit{
+				Changes: map[string][]TextEdit{params.TextDocument.URI: edits},
+			},
+		})
+	}
+	return actions
+}
+
+func (s *Server[T]) hover(ctx context.Context, params TextDocumentPositionParams) *Hover {
+	doc, ok := s.docs[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+	line, col := params.Position.Line, params.Position.Character
+	key, start, ok := doc.keyAt(line, col)
+	if !ok {
+		return nil
+	}
+
+	schema, version := s.schemaAt(ctx, doc, line)
+	if schema == nil {
+		return nil
+	}
+	path := append(doc.pathAt(line, start), key)
+	change, ok := schema.FindChange(version, path)
+	if !ok {
+		return nil
+	}
+
+	return &Hover{
+		Contents: MarkupContent{Kind: "plaintext", Value: change.Message},
+		Range: &Range{
+			Start: Position{Line: line, Character: start},
+			End:   Position{Line: line, Character: start + utf16Len(key)},
+		},
+	}
+}
+
+func (s *Server[T]) completion(ctx context.Context, params TextDocumentPositionParams) *CompletionList {
+	list := &CompletionList{Items: []CompletionItem{}}
+	doc, ok := s.docs[params.TextDocument.URI]
+	if !ok {
+		return list
+	}
+	line, col := params.Position.Line, params.Position.Character
+
+	schema, version := s.schemaAt(ctx, doc, line)
+	if schema == nil {
+		return list
+	}
+
+	// complete keys at the indentation of the word under the cursor
+	start := col
+	if line < len(doc.lines) {
+		l := doc.lines[line]
+		l = l[:min(byteOffset(l, col), len(l))]
+		start = utf16Len(strings.TrimRight(l, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_."))
+	}
+	path := doc.pathAt(line, start)
+
+	for _, name := range schema.FieldNames(version, path) {
+		item := CompletionItem{
+			Label:      name,
+			Kind:       CompletionItemKindField,
+			InsertText: name + ": ",
+		}
+		if c, ok := schema.FindChange(version, append(slices.Clip(path), name)); ok && c.ChangeType == evolviconf.FieldDeprecated {
+			item.Detail = c.Message
+			item.Tags = []CompletionItemTag{CompletionItemTagDeprecated}
+		}
+		list.Items = append(list.Items, item)
+	}
+	return list
+}
+
+// schemaAt returns the schema and version of the YAML document containing
+// line. If the document does not specify a version, the latest known version
+// is used.
+func (s *Server[T]) schemaAt(ctx context.Context, doc document, line int) (Schema, *semver.Version) {
+	var latest Schema
+	for _, schema := range s.schemas {
+		if latest == nil || s.compareVersions(schema.LatestKnownVersion(), latest.LatestKnownVersion()) > 0 {
+			latest = schema
+		}
+	}
+	if latest == nil {
+		return nil, nil
+	}
+
+	dec := yaml.NewDecoder(strings.NewReader(doc.yamlDocumentAt(line)))
+	version, err := latest.ParseVersion(ctx, dec)
+	if err != nil {
+		return latest, latest.LatestKnownVersion()
+	}
+
+	var bestMatch Schema
+	for _, schema := range s.schemas {
+		if !evolviconf.CheckConstraint(schema, schema.Constraint(), version) {
+			continue
+		}
+		if bestMatch == nil || s.compareVersions(schema.LatestKnownVersion(), bestMatch.LatestKnownVersion()) > 0 {
+			bestMatch = schema
+		}
+	}
+	if bestMatch == nil {
+		return latest, latest.LatestKnownVersion()
+	}
+	return bestMatch, version
+}
+
+// compareVersions compares versions with the version scheme of the parser.
+func (s *Server[T]) compareVersions(a, b *semver.Version) int {
+	return s.parser.VersionScheme().Compare(a, b)
+}
+
+func unmarshalParams(raw json.RawMessage, v any) error {
+	if err := json.Unmarshal(raw, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: fmt.Sprintf("invalid params: %v", err)}
+	}
+	return nil
+}
@@ -0,0 +1,494 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/conduitio/evolviconf"
+	"github.com/conduitio/evolviconf/evolviyaml"
+	"github.com/conduitio/evolviconf/evolviyaml/example/yaml/model"
+	v1 "github.com/conduitio/evolviconf/evolviyaml/example/yaml/v1"
+	v2 "github.com/conduitio/evolviconf/evolviyaml/example/yaml/v2"
+	"github.com/matryer/is"
+)
+
+const testDocument = `version: 2.0
+pipelines:
+  - id: pipeline1
+    unknownField: x
+    processors:
+      - id: proc1
+        condition: foo
+` + "        \n" // cursor for completion
+
+func TestServer(t *testing.T) {
+	is := is.New(t)
+	client := newTestClient(t)
+
+	var initResult InitializeResult
+	client.request("initialize", map[string]any{}, &initResult)
+	is.Equal(initResult.Capabilities.TextDocumentSync, textDocumentSyncFull)
+	is.True(initResult.Capabilities.HoverProvider)
+	is.True(initResult.Capabilities.CodeActionProvider)
+	client.notify("initialized", map[string]any{})
+
+	// opening a document publishes the warnings as diagnostics
+	client.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: "file:///pipelines.yml", Text: testDocument},
+	})
+	diagnostics := client.diagnostics()
+	is.Equal(diagnostics, PublishDiagnosticsParams{
+		URI: "file:///pipelines.yml",
+		Diagnostics: []Diagnostic{{
+			// missing fields are reported at the closest parent, the range
+			// covers the sequence item up to the end of the line
+			Range:    Range{Start: Position{Line: 2, Character: 4}, End: Position{Line: 2, Character: 17}},
+			Severity: SeverityWarning,
+			Source:   "evolviconf",
+			Message:  "field pipelines.0.dead-letter-queue.window-size is not set and defaults to 1 in version 2.0: the default of field window-size changes to 4 in version 2.2",
+		}, {
+			Range:    Range{Start: Position{Line: 3, Character: 4}, End: Position{Line: 3, Character: 16}},
+			Severity: SeverityWarning,
+			Source:   "evolviconf",
+			Message:  "field unknownField not found in type v2.Pipeline",
+		}, {
+			Range:    Range{Start: Position{Line: 6, Character: 8}, End: Position{Line: 6, Character: 17}},
+			Severity: SeverityWarning,
+			Source:   "evolviconf",
+			Message:  "field condition was introduced in version 2.1, please update the pipeline config version",
+		}},
+	})
+
+	// hover shows the change message
+	var hover *Hover
+	client.request("textDocument/hover", TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: "file:///pipelines.yml"},
+		Position:     Position{Line: 6, Character: 10},
+	}, &hover)
+	is.Equal(hover, &Hover{
+		Contents: MarkupContent{
+			Kind:  "plaintext",
+			Value: "field condition was introduced in version 2.1, please update the pipeline config version",
+		},
+		Range: &Range{Start: Position{Line: 6, Character: 8}, End: Position{Line: 6, Character: 17}},
+	})
+
+	// no hover for fields without changes
+	hover = nil
+	client.request("textDocument/hover", TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: "file:///pipelines.yml"},
+		Position:     Position{Line: 5, Character: 9},
+	}, &hover)
+	is.Equal(hover, nil)
+
+	// completion only contains fields valid in version 2.0
+	var completion CompletionList
+	client.request("textDocument/completion", TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: "file:///pipelines.yml"},
+		Position:     Position{Line: 7, Character: 8},
+	}, &completion)
+	is.Equal(labels(completion), []string{"id", "type", "settings", "workers"})
+
+	// after updating the version, new fields are completed and deprecated
+	// fields are tagged
+	client.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: "file:///pipelines.yml"},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "version: 2.2\npipelines:\n  - id: p1\n    processors:\n      - \n"}},
+	})
+	diagnostics = client.diagnostics()
+	is.Equal(len(diagnostics.Diagnostics), 0)
+
+	completion = CompletionList{}
+	client.request("textDocument/completion", TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: "file:///pipelines.yml"},
+		Position:     Position{Line: 4, Character: 8},
+	}, &completion)
+	is.Equal(labels(completion), []string{"id", "type", "plugin", "condition", "settings", "workers"})
+	is.Equal(completion.Items[1].Tags, []CompletionItemTag{CompletionItemTagDeprecated})
+	is.Equal(completion.Items[1].Detail, "please use field 'plugin' (introduced in version 2.2)")
+
+	// deprecated fields with a replacement have a quick fix
+	client.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: "file:///pipelines.yml"},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "version: 2.2\npipelines:\n  - id: p1\n    processors:\n      - id: proc1\n        type: js\n"}},
+	})
+	diagnostics = client.diagnostics()
+	is.Equal(len(diagnostics.Diagnostics), 1)
+
+	var actions []CodeAction
+	client.request("textDocument/codeAction", CodeActionParams{
+		TextDocument: TextDocumentIdentifier{URI: "file:///pipelines.yml"},
+		Range:        Range{Start: Position{Line: 5, Character: 8}, End: Position{Line: 5, Character: 8}},
+	}, &actions)
+	is.Equal(actions, []CodeAction{{
+		Title:       "rename field type to plugin",
+		Kind:        CodeActionKindQuickFix,
+		Diagnostics: diagnostics.Diagnostics,
+		Edit: &WorkspaceEdit{
+			Changes: map[string][]TextEdit{
+				"file:///pipelines.yml": {{
+					Range:   Range{Start: Position{Line: 5, Character: 8}, End: Position{Line: 5, Character: 12}},
+					NewText: "plugin",
+				}},
+			},
+		},
+	}})
+
+	// no quick fixes outside of the requested range
+	actions = nil
+	client.request("textDocument/codeAction", CodeActionParams{
+		TextDocument: TextDocumentIdentifier{URI: "file:///pipelines.yml"},
+		Range:        Range{Start: Position{Line: 0, Character: 0}, End: Position{Line: 1, Character: 0}},
+	}, &actions)
+	is.Equal(len(actions), 0)
+
+	// invalid YAML produces an error diagnostic
+	client.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: "file:///pipelines.yml"},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "version: 2.2\npipelines:\n  - id: p1\n      status: x\n"}},
+	})
+	diagnostics = client.diagnostics()
+	is.Equal(len(diagnostics.Diagnostics), 1)
+	is.Equal(diagnostics.Diagnostics[0].Severity, SeverityError)
+	is.Equal(diagnostics.Diagnostics[0].Range.Start.Line, 3)
+
+	// closing the document clears the diagnostics
+	client.notify("textDocument/didClose", DidCloseTextDocumentParams{
+		TextDocument: TextDocumentIdentifier{URI: "file:///pipelines.yml"},
+	})
+	is.Equal(len(client.diagnostics().Diagnostics), 0)
+
+	// unknown methods return an error
+	err := client.requestErr("textDocument/unknown", map[string]any{})
+	is.Equal(err.Code, codeMethodNotFound)
+
+	client.request("shutdown", nil, nil)
+	client.notify("exit", nil)
+	is.NoErr(client.wait())
+}
+
+func TestServer_ErrorDiagnostic(t *testing.T) {
+	testCases := []struct {
+		name string
+		text string
+		want Range
+	}{{
+		name: "syntax error",
+		text: "version: 2.2\npipelines:\n  - id: p1\n      status: x\n",
+		want: Range{Start: Position{Line: 3, Character: 0}, End: Position{Line: 3, Character: 15}},
+	}, {
+		name: "invalid value",
+		text: "version: 2.2\npipelines:\n  - id: p1\n    dead-letter-queue:\n      window-size: abc\n",
+		want: Range{Start: Position{Line: 4, Character: 19}, End: Position{Line: 4, Character: 22}},
+	}, {
+		name: "version is not a scalar",
+		text: "version:\n  - 2.2\n",
+		want: Range{Start: Position{Line: 1, Character: 2}, End: Position{Line: 1, Character: 7}},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			client := newTestClient(t)
+
+			client.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+				TextDocument: TextDocumentItem{URI: "file:///pipelines.yml", Text: tc.text},
+			})
+			diagnostics := client.diagnostics()
+			is.Equal(len(diagnostics.Diagnostics), 1)
+			is.Equal(diagnostics.Diagnostics[0].Severity, SeverityError)
+			is.Equal(diagnostics.Diagnostics[0].Range, tc.want)
+		})
+	}
+}
+
+func TestServer_PrereleaseCompletion(t *testing.T) {
+	is := is.New(t)
+	client := newTestClient(t)
+
+	// the v2 parser opted in to pre-releases, so the document uses version
+	// 2.1.0-beta.1 and fields introduced in 2.1 are not completed
+	client.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{
+			URI:  "file:///pipelines.yml",
+			Text: "version: 2.1.0-beta.1\npipelines:\n  - id: p1\n    processors:\n      - \n",
+		},
+	})
+	for _, d := range client.diagnostics().Diagnostics {
+		is.Equal(d.Severity, SeverityWarning) // the document is parsed
+	}
+
+	var completion CompletionList
+	client.request("textDocument/completion", TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: "file:///pipelines.yml"},
+		Position:     Position{Line: 4, Character: 8},
+	}, &completion)
+	is.Equal(labels(completion), []string{"id", "type", "settings", "workers"})
+}
+
+func TestServer_IncludedWarnings(t *testing.T) {
+	is := is.New(t)
+	v2Parser := evolviyaml.NewParser[model.Configuration, v2.Configuration](
+		mustConstraint(t, "^2"),
+		v2.Changelog,
+	).WithIncludes(fstest.MapFS{
+		"processors.yml": {Data: []byte("- id: proc1\n  type: js\n")},
+	})
+	client := newTestClientWithServer(t, NewServer(evolviconf.NewParser(v2Parser), v2Parser))
+
+	// the deprecated field in the included file is not reported in the
+	// document, only the unknown field is
+	client.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{
+			URI:  "file:///pipelines.yml",
+			Text: "version: 2.2\npipelines:\n  - id: p1\n    unknownField: x\n    processors: !include processors.yml\n",
+		},
+	})
+	diagnostics := client.diagnostics()
+	is.Equal(diagnostics.Diagnostics, []Diagnostic{{
+		Range:    Range{Start: Position{Line: 3, Character: 4}, End: Position{Line: 3, Character: 16}},
+		Severity: SeverityWarning,
+		Source:   "evolviconf",
+		Message:  "field unknownField not found in type v2.Pipeline",
+	}})
+
+	// warnings in included files have no quick fixes
+	var actions []CodeAction
+	client.request("textDocument/codeAction", CodeActionParams{
+		TextDocument: TextDocumentIdentifier{URI: "file:///pipelines.yml"},
+		Range:        Range{Start: Position{Line: 0, Character: 0}, End: Position{Line: 5, Character: 0}},
+	}, &actions)
+	is.Equal(len(actions), 0)
+}
+
+func TestServer_UTF16Positions(t *testing.T) {
+	is := is.New(t)
+	client := newTestClient(t)
+
+	// characters count UTF-16 code units, "😀" counts as 2, "ö" as 1
+	client.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{
+			URI:  "file:///pipelines.yml",
+			Text: "version: 2.2\npipelines:\n  - id: p1\n    processors:\n      - {id: \"pröc😀\", type: js}\n",
+		},
+	})
+	diagnostics := client.diagnostics()
+	is.Equal(diagnostics.Diagnostics, []Diagnostic{{
+		Range:    Range{Start: Position{Line: 4, Character: 23}, End: Position{Line: 4, Character: 27}},
+		Severity: SeverityWarning,
+		Source:   "evolviconf",
+		Message:  "please use field 'plugin' (introduced in version 2.2)",
+	}})
+
+	var actions []CodeAction
+	client.request("textDocument/codeAction", CodeActionParams{
+		TextDocument: TextDocumentIdentifier{URI: "file:///pipelines.yml"},
+		Range:        Range{Start: Position{Line: 4, Character: 23}, End: Position{Line: 4, Character: 23}},
+	}, &actions)
+	is.Equal(len(actions), 1)
+	is.Equal(actions[0].Edit.Changes["file:///pipelines.yml"], []TextEdit{{
+		Range:   Range{Start: Position{Line: 4, Character: 23}, End: Position{Line: 4, Character: 27}},
+		NewText: "plugin",
+	}})
+}
+
+func TestServer_ContextCancelled(t *testing.T) {
+	is := is.New(t)
+	server := NewServer[model.Configuration](nil)
+
+	// the client never sends anything, Serve blocks in read
+	serverIn, clientOut := io.Pipe()
+	t.Cleanup(func() { _ = clientOut.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Serve(ctx, serverIn, io.Discard)
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		is.True(errors.Is(err, context.Canceled))
+	case <-time.After(time.Second):
+		t.Fatal("Serve did not return after the context was cancelled")
+	}
+	// the reader was closed
+	_, err := clientOut.Write([]byte("x"))
+	is.True(errors.Is(err, io.ErrClosedPipe))
+}
+
+func labels(list CompletionList) []string {
+	out := make([]string, len(list.Items))
+	for i, item := range list.Items {
+		out[i] = item.Label
+	}
+	return out
+}
+
+// testClient is a scripted language server client that talks to a server
+// running in a separate goroutine.
+type testClient struct {
+	t      *testing.T
+	conn   *conn
+	nextID int
+	// pending contains notifications received while waiting for a response
+	pending []message
+	done    chan error
+}
+
+func newTestClient(t *testing.T) *testClient {
+	t.Helper()
+
+	v1Parser := evolviyaml.NewParser[model.Configuration, v1.Configuration](
+		mustConstraint(t, "^1"),
+		v1.Changelog,
+	)
+	v2Parser := evolviyaml.NewParser[model.Configuration, v2.Configuration](
+		mustConstraint(t, "^2"),
+		v2.Changelog,
+	).WithPrereleases(true)
+	return newTestClientWithServer(t, NewServer(evolviconf.NewParser(v1Parser, v2Parser), v1Parser, v2Parser))
+}
+
+func newTestClientWithServer(t *testing.T, server *Server[model.Configuration]) *testClient {
+	t.Helper()
+
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	t.Cleanup(func() {
+		_ = clientOut.Close()
+		_ = clientIn.Close()
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Serve(context.Background(), serverIn, serverOut)
+		_ = serverOut.Close()
+	}()
+
+	return &testClient{
+		t:    t,
+		conn: newConn(clientIn, clientOut),
+		done: done,
+	}
+}
+
+func mustConstraint(t *testing.T, c string) *semver.Constraints {
+	t.Helper()
+	constraint, err := semver.NewConstraint(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return constraint
+}
+
+func (c *testClient) notify(method string, params any) {
+	c.t.Helper()
+	if err := c.conn.notify(method, params); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// request sends a request and decodes the result into result.
+func (c *testClient) request(method string, params, result any) {
+	c.t.Helper()
+	msg := c.call(method, params)
+	if msg.Error != nil {
+		c.t.Fatalf("unexpected error response: %v", msg.Error)
+	}
+	if result == nil {
+		return
+	}
+	raw, err := json.Marshal(msg.Result)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err := json.Unmarshal(raw, result); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// requestErr sends a request and returns the error response.
+func (c *testClient) requestErr(method string, params any) *responseError {
+	c.t.Helper()
+	msg := c.call(method, params)
+	if msg.Error == nil {
+		c.t.Fatalf("expected error response, got %v", msg.Result)
+	}
+	return msg.Error
+}
+
+func (c *testClient) call(method string, params any) message {
+	c.t.Helper()
+	c.nextID++
+	id := json.RawMessage(fmt.Sprint(c.nextID))
+	raw, err := json.Marshal(params)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err := c.conn.write(message{ID: &id, Method: method, Params: raw}); err != nil {
+		c.t.Fatal(err)
+	}
+
+	for {
+		msg := c.read()
+		if msg.ID != nil && string(*msg.ID) == string(id) {
+			return msg
+		}
+		c.pending = append(c.pending, msg)
+	}
+}
+
+// diagnostics returns the next publishDiagnostics notification.
+func (c *testClient) diagnostics() PublishDiagnosticsParams {
+	c.t.Helper()
+	var msg message
+	if len(c.pending) > 0 {
+		msg, c.pending = c.pending[0], c.pending[1:]
+	} else {
+		msg = c.read()
+	}
+	if msg.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("expected diagnostics, got %q", msg.Method)
+	}
+	var params PublishDiagnosticsParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		c.t.Fatal(err)
+	}
+	return params
+}
+
+func (c *testClient) read() message {
+	c.t.Helper()
+	msg, err := c.conn.read()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return msg
+}
+
+// wait waits for the server to stop.
+func (c *testClient) wait() error {
+	return <-c.done
+}
@@ -19,8 +19,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"maps"
-	"sort"
+	"io/fs"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/conduitio/evolviconf"
@@ -28,26 +31,52 @@ import (
 )
 
 type Parser[T any, C evolviconf.VersionedConfig[T]] struct {
-	constraint         *semver.Constraints
-	latestKnownVersion *semver.Version
-	linter             *configLinter
-	hook               yaml.DecoderHook
+	constraint evolviconf.VersionConstraint
+	// changelog is kept in its original form, so that Validate can report
+	// duplicate versions that were merged in the linter's changelog index.
+	changelog evolviconf.Changelog
+	linter    *configLinter
+	hook      yaml.DecoderHook
+	// versionKey is the path to the version field in the document.
+	versionKey []string
+	// prereleases enables parsing pre-release versions.
+	prereleases bool
+	// secrets resolves secret references, nil if secrets are not supported.
+	secrets SecretResolver
+	// env expands environment variables, nil if expansion is disabled.
+	env *EnvExpander
+	// includes is the file system included files are read from, nil if
+	// includes are disabled.
+	includes fs.FS
 }
 
+// NewParser creates a parser that lints configurations based on the supplied
+// changelog. It panics if the changelog is empty, see NewParserFromIndex.
 func NewParser[T any, C evolviconf.VersionedConfig[T]](
-	constraint *semver.Constraints,
+	constraint evolviconf.VersionConstraint,
 	changelog evolviconf.Changelog,
 ) *Parser[T, C] {
-	var versions semver.Collection
-	for k := range maps.Keys(changelog) {
-		versions = append(versions, k)
-	}
-	sort.Sort(versions)
+	p := NewParserFromIndex[T, C](constraint, changelog.Index())
+	p.changelog = changelog
+	return p
+}
 
+// NewParserFromIndex creates a parser that lints configurations based on the
+// supplied changelog index. The latest version in the index is the latest
+// known version of the parser, so the index needs to contain at least one
+// version, otherwise NewParserFromIndex panics.
+func NewParserFromIndex[T any, C evolviconf.VersionedConfig[T]](
+	constraint evolviconf.VersionConstraint,
+	changelog *evolviconf.ChangelogIndex,
+) *Parser[T, C] {
+	if changelog.Latest() == nil {
+		panic(fmt.Sprintf("evolviyaml: changelog of %T contains no versions, add at least one changelog entry", zero[C]()))
+	}
 	return &Parser[T, C]{
-		constraint:         constraint,
-		latestKnownVersion: versions[len(versions)-1],
-		linter:             newConfigLinter(changelog),
+		constraint: constraint,
+		changelog:  changelog.Changelog(),
+		linter:     newConfigLinter(changelog, evolviconf.NewRedactor(evolviconf.SensitiveFields(reflect.TypeFor[C](), "yaml")...)),
+		versionKey: []string{"version"},
 	}
 }
 
@@ -56,73 +85,425 @@ func (p *Parser[T, C]) WithHook(hook yaml.DecoderHook) *Parser[T, C] {
 	return p
 }
 
+// WithVersionKey sets the path to the field containing the version of the
+// document, nested keys are separated by dots (e.g. "metadata.version"). The
+// default is "version". Note that evolviconf.NewParser uses the first parser
+// to parse versions.
+func (p *Parser[T, C]) WithVersionKey(path string) *Parser[T, C] {
+	p.versionKey = strings.Split(path, ".")
+	return p
+}
+
+// SetVersionScheme sets the scheme used to parse, compare and format
+// versions, see evolviconf.VersionSchemeSetter. It is called by
+// evolviconf.Parser.WithVersionScheme, the default is
+// evolviconf.SemverScheme. The constraint and changelog of the parser need to
+// use the same scheme, see evolviconf.MustParseVersion.
+func (p *Parser[T, C]) SetVersionScheme(scheme evolviconf.VersionScheme) {
+	p.linter.changelog = p.linter.changelog.WithScheme(scheme)
+}
+
+// WithRedactedFields marks the fields matching the patterns as sensitive, in
+// addition to the fields tagged with `evolvi:"sensitive"` in the versioned
+// config C. Values of sensitive fields are replaced with
+// evolviconf.RedactedValue in warnings, see evolviconf.NewRedactor for the
+// pattern syntax.
+func (p *Parser[T, C]) WithRedactedFields(patterns ...string) *Parser[T, C] {
+	p.linter.redactor = p.linter.redactor.With(patterns...)
+	return p
+}
+
+// WithEnvExpander enables the expansion of environment variables in string
+// values with the supplied expander. Variables are expanded after secrets are
+// resolved and before other hooks run, so secret references in variables are
+// not resolved and resolved secrets are not expanded. Unset variables and
+// malformed references are reported as warnings, see EnvExpander.
+func (p *Parser[T, C]) WithEnvExpander(env *EnvExpander) *Parser[T, C] {
+	p.env = env
+	return p
+}
+
+// WithSecretResolver enables secret references in documents, resolved with
+// the supplied resolver. Scalars tagged with SecretTag (e.g. `!secret
+// aws-key`) are replaced with the secret, references embedded in strings
+// (e.g. "${secret:aws-key}") are replaced in place. Secrets are resolved
+// before other hooks run, including the expansion of environment variables
+// (see WithEnvExpander). Values of fields containing resolved secrets are
+// masked with SecretMask in the warnings about those fields and in errors
+// returned after secrets are resolved. A secret that can't be resolved fails
+// the parsing of the document.
+func (p *Parser[T, C]) WithSecretResolver(resolver SecretResolver) *Parser[T, C] {
+	p.secrets = resolver
+	return p
+}
+
+// WithIncludes enables file includes in documents, included files are read
+// from fsys (e.g. os.DirFS("pipelines")). Scalars tagged with IncludeTag (e.g.
+// `!include connectors/s3.yml`) are replaced with the document in the file.
+// Paths in the parsed document are relative to the root of fsys, paths in
+// included files are relative to the directory of the including file.
+// Included documents go through the same hooks and linting as the parsed
+// document, warnings about fields in included files contain the file in
+// evolviconf.Position.File. A file that can't be read or an include cycle
+// (see ErrIncludeCycle) fails the parsing of the document.
+func (p *Parser[T, C]) WithIncludes(fsys fs.FS) *Parser[T, C] {
+	p.includes = fsys
+	return p
+}
+
+// WithPrereleases opts in to parsing pre-release versions (e.g.
+// 2.3.0-beta.1) whose release version satisfies the constraint of the parser,
+// see evolviconf.PrereleaseAcceptor. Changes in the changelog can be tied to
+// pre-release versions, they apply to the pre-release and all later versions.
+func (p *Parser[T, C]) WithPrereleases(accept bool) *Parser[T, C] {
+	p.prereleases = accept
+	return p
+}
+
+// AcceptsPrerelease returns true if the parser accepts pre-release versions,
+// see WithPrereleases.
+func (p *Parser[T, C]) AcceptsPrerelease() bool {
+	return p.prereleases
+}
+
+// Validate checks the changelog for inconsistencies and makes sure all fields
+// referenced in the changelog exist in the versioned config C. It is meant to
+// be called in tests.
+func (p *Parser[T, C]) Validate() error {
+	return p.changelog.ValidateType(reflect.TypeFor[C](), "yaml")
+}
+
+// JSONSchema generates a JSON Schema of the versioned config C for the
+// supplied version, see evolviconf.GenerateJSONSchema.
+func (p *Parser[T, C]) JSONSchema(version *semver.Version) (*evolviconf.JSONSchema, error) {
+	return evolviconf.GenerateJSONSchema(reflect.TypeFor[C](), "yaml", version, p.linter.changelog)
+}
+
+// JSONSchemas generates a JSON Schema for every version in the changelog. The
+// returned map is keyed by the version formatted with the version scheme of
+// the parser.
+func (p *Parser[T, C]) JSONSchemas() (map[string]*evolviconf.JSONSchema, error) {
+	schemas := make(map[string]*evolviconf.JSONSchema)
+	for _, v := range p.linter.changelog.Versions() {
+		schema, err := p.JSONSchema(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate JSON Schema for version %s: %w", p.linter.changelog.Scheme().FormatVersion(v), err)
+		}
+		schemas[p.linter.changelog.Scheme().FormatVersion(v)] = schema
+	}
+	return schemas, nil
+}
+
+// FindChange returns the change that applies to the field at path in a
+// configuration with the supplied version. Indices and map keys in the path
+// are matched against wildcards in the changelog.
+func (p *Parser[T, C]) FindChange(version *semver.Version, path []string) (evolviconf.Change, bool) {
+	return evolviconf.FindChange(p.linter.changelog.Rules(version), path)
+}
+
+// FieldNames returns the names of the fields that can be used at path in a
+// configuration with the supplied version. Fields introduced in newer versions
+// are omitted, deprecated fields are included.
+func (p *Parser[T, C]) FieldNames(version *semver.Version, path []string) []string {
+	names := evolviconf.FieldNames(reflect.TypeFor[C](), "yaml", path)
+	return slices.DeleteFunc(names, func(name string) bool {
+		c, ok := p.FindChange(version, append(slices.Clip(path), name))
+		return ok && c.ChangeType == evolviconf.FieldIntroduced
+	})
+}
+
 func (p *Parser[T, C]) Decoder(reader io.Reader) *yaml.Decoder {
 	return yaml.NewDecoder(reader)
 }
 
+// Encoder returns a YAML encoder with an indentation of 2 spaces, it writes
+// multiple documents separated by "---". See evolviconf.Parser.Encode.
+func (p *Parser[T, C]) Encoder(writer io.Writer) evolviconf.Encoder {
+	enc := yaml.NewEncoder(writer)
+	enc.SetIndent(2)
+	return enc
+}
+
+// EncodeVersionedConfig encodes the versioned config as a YAML document and
+// sets the version field to version, see evolviconf.VersionedConfigEncoder.
+// Empty fields are omitted (see evolviconf.EmptyFields), so that the document
+// only contains the fields that are set and can be parsed without warnings
+// about empty fields that are deprecated or not yet introduced in the
+// version.
+func (p *Parser[T, C]) EncodeVersionedConfig(_ context.Context, enc evolviconf.Encoder, config evolviconf.VersionedConfig[T], version *semver.Version) error {
+	var node yaml.Node
+	if err := node.Encode(config); err != nil {
+		return err
+	}
+	empty := make(map[string]bool)
+	for _, f := range evolviconf.EmptyFields(config, "yaml") {
+		empty[strings.Join(f, ".")] = true
+	}
+	pruneFields(&node, empty, nil)
+	if err := setNode(&node, p.versionKey, p.linter.changelog.Scheme().FormatVersion(version)); err != nil {
+		return err
+	}
+	return enc.Encode(&node)
+}
+
 func (p *Parser[T, C]) LatestKnownVersion() *semver.Version {
-	return p.latestKnownVersion
+	return p.linter.changelog.Latest()
 }
 
-func (p *Parser[T, C]) Constraint() *semver.Constraints {
+// OldestKnownVersion returns the oldest version in the changelog, see
+// evolviconf.OldestKnownVersioner.
+func (p *Parser[T, C]) OldestKnownVersion() *semver.Version {
+	versions := p.linter.changelog.Versions()
+	if len(versions) == 0 {
+		return nil
+	}
+	return versions[0]
+}
+
+func (p *Parser[T, C]) Constraint() evolviconf.VersionConstraint {
 	return p.constraint
 }
 
 func (p *Parser[T, C]) ParseVersion(_ context.Context, dec *yaml.Decoder) (*semver.Version, error) {
-	var out struct {
-		Version string `yaml:"version"`
-	}
-
-	err := dec.Decode(&out)
+	var doc yaml.Node
+	err := dec.Decode(&doc)
 	if err != nil {
 		return nil, err
 	}
+	return versionFromNode(&doc, p.versionKey, p.linter.changelog.Scheme())
+}
 
-	if out.Version == "" {
+// versionFromNode returns the version found at path in the document parsed
+// with scheme. It returns evolviconf.ErrVersionNotSpecified if the version is
+// missing.
+func versionFromNode(doc *yaml.Node, path []string, scheme evolviconf.VersionScheme) (*semver.Version, error) {
+	node := lookupNode(doc, path)
+	if node != nil && node.Kind != yaml.ScalarNode {
+		return nil, errorAt(node, path, fmt.Errorf("line %d: field %s must be a scalar", node.Line, strings.Join(path, ".")))
+	}
+	if node == nil || node.Value == "" || node.ShortTag() == "!!null" {
 		return nil, evolviconf.ErrVersionNotSpecified
 	}
 
-	version, err := semver.NewVersion(out.Version)
+	version, err := scheme.ParseVersion(node.Value)
 	return version, err
 }
 
-func (p *Parser[T, C]) ParseVersionedConfig(_ context.Context, dec *yaml.Decoder, version *semver.Version) (evolviconf.VersionedConfig[T], evolviconf.Warnings, error) {
+// pruneFields removes the fields from the node, the keys of fields are the
+// paths of the fields joined with dots.
+func pruneFields(node *yaml.Node, fields map[string]bool, path []string) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, n := range node.Content {
+			pruneFields(n, fields, path)
+		}
+	case yaml.SequenceNode:
+		for i, n := range node.Content {
+			pruneFields(n, fields, append(slices.Clip(path), strconv.Itoa(i)))
+		}
+	case yaml.MappingNode:
+		content := node.Content[:0]
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			fieldPath := append(slices.Clip(path), key.Value)
+			if fields[strings.Join(fieldPath, ".")] {
+				continue
+			}
+			pruneFields(value, fields, fieldPath)
+			content = append(content, key, value)
+		}
+		node.Content = content
+	}
+}
+
+// setNode sets the value at path in a document to the scalar value. Missing
+// mappings are created, new keys are inserted at the beginning of the
+// mapping.
+func setNode(node *yaml.Node, path []string, value string) error {
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			node.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+		}
+		node = node.Content[0]
+	}
+	for i, key := range path {
+		if node.Kind != yaml.MappingNode {
+			return fmt.Errorf("can't set field %s, %s is not a mapping", strings.Join(path, "."), strings.Join(path[:i], "."))
+		}
+		var next *yaml.Node
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			if node.Content[j].Value == key {
+				next = node.Content[j+1]
+				break
+			}
+		}
+		if next == nil {
+			next = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			node.Content = append([]*yaml.Node{{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, next}, node.Content...)
+		}
+		node = next
+	}
+	*node = yaml.Node{Kind: yaml.ScalarNode, Value: value}
+	return nil
+}
+
+// lookupNode returns the value node at path in a document, or nil if it
+// doesn't exist.
+func lookupNode(node *yaml.Node, path []string) *yaml.Node {
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		node = node.Content[0]
+	}
+	for _, key := range path {
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				next = node.Content[i+1]
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+	return node
+}
+
+// FromConfig converts the config to the versioned config C, see
+// evolviconf.VersionedConfigConverter. A pointer to C needs to implement
+// evolviconf.ConfigFromer.
+func (p *Parser[T, C]) FromConfig(_ context.Context, config T, version *semver.Version) (evolviconf.VersionedConfig[T], evolviconf.Warnings, error) {
+	var c C
+	f, ok := any(&c).(evolviconf.ConfigFromer[T])
+	if !ok {
+		return nil, nil, fmt.Errorf("versioned config %T does not implement evolviconf.ConfigFromer", c)
+	}
+	warnings, err := f.FromConfig(config, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, warnings, nil
+}
+
+// LostFields returns warnings about fields in the versioned config that are
+// introduced after version to according to the changelog, see
+// evolviconf.VersionedConfigConverter.
+func (p *Parser[T, C]) LostFields(config evolviconf.VersionedConfig[T], _, to *semver.Version) evolviconf.Warnings {
+	return evolviconf.LostFields(config, "yaml", p.linter.changelog, to)
+}
+
+func (p *Parser[T, C]) ParseVersionedConfig(ctx context.Context, dec *yaml.Decoder, version *semver.Version) (evolviconf.VersionedConfig[T], evolviconf.Warnings, error) {
 	// set up decoder hooks
 	var warn evolviconf.Warnings
-	dec.KnownFields(true)
-	dec.WithHook(MultiDecoderHook(
+	var secrets *secretResolution
+	if p.secrets != nil {
+		// escape resolved values, so they are not expanded as variables
+		secrets = &secretResolution{ctx: ctx, resolver: p.secrets, escapeEnv: p.env != nil}
+	}
+	var envHook yaml.DecoderHook
+	if p.env != nil {
+		envHook = p.env.decoderHook(&warn, p.linter.redactor)
+	}
+	hook := MultiDecoderHook(
+		secrets.decoderHook(),
+		envHook,
 		p.hook,
 		p.linter.DecoderHook(version, &warn), // lint config as it's parsed
-	))
+		p.positionsHook(ctx),
+	)
+	var includes *includer
+	if p.includes != nil {
+		// included documents go through the same hooks
+		includes = &includer{
+			fsys:      p.includes,
+			next:      hook,
+			warn:      &warn,
+			positions: evolviconf.FieldPositionsFromContext(ctx),
+		}
+		hook = includes.hook
+	}
+	dec.KnownFields(true)
+	dec.WithHook(hook)
 
 	cfg := zero[C]()
 	err := dec.Decode(&cfg)
+	if includes != nil {
+		// failed includes cause decoding errors, report the cause instead
+		if err := includes.err(); err != nil {
+			return zero[C](), nil, secrets.maskErr(err)
+		}
+	}
 	if err != nil {
 		// check if it's a type error (document was partially decoded)
 		var typeErr *yaml.TypeError
 		if errors.As(err, &typeErr) {
 			var w evolviconf.Warnings
-			w, err = p.yamlTypeErrorToWarnings(typeErr)
+			w, err = p.yamlTypeErrorToWarnings(ctx, typeErr)
 			warn = append(warn, w...)
 		}
 		// check if we recovered from the error
 		if err != nil {
-			return zero[C](), nil, fmt.Errorf("decoding error: %w", err)
+			return zero[C](), nil, fmt.Errorf("decoding error: %w", secrets.maskErr(err))
+		}
+	}
+
+	if secrets != nil {
+		if err := secrets.err(); err != nil {
+			return zero[C](), nil, secrets.maskErr(err)
 		}
+		secrets.maskWarnings(warn)
 	}
+	p.linter.redactPositions(evolviconf.FieldPositionsFromContext(ctx))
+
+	// fill in fields that are not set with the defaults of this version
+	w, err := evolviconf.ApplyDefaults(ctx, &cfg, "yaml", p.linter.changelog, version)
+	if err != nil {
+		return zero[C](), nil, secrets.maskErr(err)
+	}
+	warn = append(warn, w...)
 
 	return cfg, warn, nil
 }
 
+// positionsHook returns a hook that records the positions of fields in the
+// field positions carried by ctx, so they can be used to position warnings
+// about defaults and in evolviconf.ContextVersionedConfig.ToConfigContext.
+// It returns nil if ctx doesn't carry positions.
+func (p *Parser[T, C]) positionsHook(ctx context.Context) yaml.DecoderHook {
+	positions := evolviconf.FieldPositionsFromContext(ctx)
+	if positions == nil {
+		return nil
+	}
+	return func(path []string, node *yaml.Node) {
+		if len(path) == 0 {
+			return
+		}
+		positions[strings.Join(path, ".")] = evolviconf.Position{
+			Field:  path[len(path)-1],
+			Line:   node.Line,
+			Column: node.Column,
+			Value:  node.Value,
+		}
+	}
+}
+
 // yamlTypeErrorToWarnings converts yaml.TypeError to warnings if it only
 // contains recoverable errors. If it contains at least one actual error it
-// returns nil and the error itself.
-func (p *Parser[T, C]) yamlTypeErrorToWarnings(typeErr *yaml.TypeError) (evolviconf.Warnings, error) {
+// returns nil and the error itself. Values of sensitive fields are redacted,
+// the path of a field is looked up in the positions carried by ctx.
+func (p *Parser[T, C]) yamlTypeErrorToWarnings(ctx context.Context, typeErr *yaml.TypeError) (evolviconf.Warnings, error) {
+	positions := evolviconf.FieldPositionsFromContext(ctx)
 	warn := make(evolviconf.Warnings, len(typeErr.Errors))
 	for i, uerr := range typeErr.Errors {
 		switch uerr := uerr.(type) {
 		case *yaml.UnknownFieldError:
-			warn[i] = evolviconf.Warning{
+			w := evolviconf.Warning{
 				Position: evolviconf.Position{
 					Field:  uerr.Field(),
 					Line:   uerr.Line(),
@@ -130,7 +511,11 @@ func (p *Parser[T, C]) yamlTypeErrorToWarnings(typeErr *yaml.TypeError) (evolvic
 					Value:  "", // no value in UnknownFieldError
 				},
 				Message: uerr.Error(),
+				Code:    evolviconf.CodeUnknownField,
 			}
+			path, pos := fieldPathAt(positions, w.Position)
+			w.File = pos.File // fields in included files are positioned in that file
+			warn[i] = p.linter.redactor.RedactWarning(w, path)
 		default:
 			// we don't tolerate any other errors
 			return nil, typeErr
@@ -139,6 +524,18 @@ func (p *Parser[T, C]) yamlTypeErrorToWarnings(typeErr *yaml.TypeError) (evolvic
 	return warn, nil
 }
 
+// fieldPathAt returns the path and the position of the field recorded in
+// positions at the position pos, or only the field name and pos if it's not
+// found.
+func fieldPathAt(positions evolviconf.FieldPositions, pos evolviconf.Position) ([]string, evolviconf.Position) {
+	for k, p := range positions {
+		if p.Line == pos.Line && p.Column == pos.Column && p.Field == pos.Field {
+			return strings.Split(k, "."), p
+		}
+	}
+	return []string{pos.Field}, pos
+}
+
 func zero[T any]() T {
 	var t T
 	return t
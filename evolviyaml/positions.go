@@ -0,0 +1,90 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviyaml
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/conduitio/evolviconf"
+	"github.com/conduitio/yaml/v3"
+)
+
+// Positions returns the positions of all fields in the YAML documents read
+// from r, one map per document. The maps are keyed by the path of the field
+// with tokens separated by dots and sequence items identified by their index
+// (e.g. "pipelines.0.status"), which matches evolviconf.Difference.Path, so
+// the maps can be used in evolviconf.DiffOptions. Fields in mappings are
+// positioned at their key, sequence items at the start of the item.
+func Positions(r io.Reader) ([]map[string]evolviconf.Position, error) {
+	dec := yaml.NewDecoder(r)
+
+	var out []map[string]evolviconf.Position
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode YAML: %w", err)
+		}
+
+		positions := make(map[string]evolviconf.Position)
+		for _, n := range doc.Content {
+			collectPositions(positions, nil, n)
+		}
+		out = append(out, positions)
+	}
+	return out, nil
+}
+
+func collectPositions(positions map[string]evolviconf.Position, path []string, node *yaml.Node) {
+	switch node.Kind { //nolint:exhaustive // only containers have nested fields
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			p := append(path[:len(path):len(path)], key.Value)
+			positions[strings.Join(p, ".")] = evolviconf.Position{
+				Field:  key.Value,
+				Line:   key.Line,
+				Column: key.Column,
+				Value:  scalarValue(value),
+			}
+			collectPositions(positions, p, value)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			p := append(path[:len(path):len(path)], strconv.Itoa(i))
+			positions[strings.Join(p, ".")] = evolviconf.Position{
+				Field:  strconv.Itoa(i),
+				Line:   item.Line,
+				Column: item.Column,
+				Value:  scalarValue(item),
+			}
+			collectPositions(positions, p, item)
+		}
+	}
+}
+
+func scalarValue(node *yaml.Node) string {
+	if node.Kind == yaml.ScalarNode {
+		return node.Value
+	}
+	return ""
+}
@@ -0,0 +1,291 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviyaml
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/conduitio/evolviconf"
+	"github.com/conduitio/yaml/v3"
+)
+
+// SecretTag is the YAML tag that marks a scalar as a secret reference, the
+// value of the scalar is the name of the secret (e.g. `key: !secret aws-key`).
+const SecretTag = "!secret"
+
+// SecretMask replaces resolved secret values in warnings and errors.
+const SecretMask = "******"
+
+// ErrSecretNotFound is returned by a SecretResolver if the secret doesn't
+// exist.
+var ErrSecretNotFound = errors.New("secret not found")
+
+// secretRefRegex matches secret references embedded in strings, e.g.
+// "${secret:aws-key}".
+var secretRefRegex = regexp.MustCompile(`\$\{secret:([^}]+)\}`)
+
+// SecretResolver resolves the value of a secret by its name.
+type SecretResolver interface {
+	ResolveSecret(ctx context.Context, name string) (string, error)
+}
+
+// SecretResolverFunc is an adapter that allows using a function as a
+// SecretResolver.
+type SecretResolverFunc func(ctx context.Context, name string) (string, error)
+
+func (f SecretResolverFunc) ResolveSecret(ctx context.Context, name string) (string, error) {
+	return f(ctx, name)
+}
+
+// EnvSecretResolver returns a resolver that reads secrets from environment
+// variables named prefix followed by the secret name (e.g. prefix "SECRET_"
+// resolves the secret "AWS_KEY" from the variable "SECRET_AWS_KEY").
+func EnvSecretResolver(prefix string) SecretResolver {
+	return SecretResolverFunc(func(_ context.Context, name string) (string, error) {
+		v, ok := os.LookupEnv(prefix + name)
+		if !ok {
+			return "", fmt.Errorf("%w: environment variable %s is not set", ErrSecretNotFound, prefix+name)
+		}
+		return v, nil
+	})
+}
+
+// FileSecretResolver returns a resolver that reads secrets from files in
+// fsys, the name of the secret is the path of the file (e.g. with
+// os.DirFS("/run/secrets")). A single trailing newline is removed from the
+// file contents.
+func FileSecretResolver(fsys fs.FS) SecretResolver {
+	return SecretResolverFunc(func(_ context.Context, name string) (string, error) {
+		b, err := fs.ReadFile(fsys, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("%w: %w", ErrSecretNotFound, err)
+		}
+		if err != nil {
+			return "", err
+		}
+		s := strings.TrimSuffix(string(b), "\n")
+		return strings.TrimSuffix(s, "\r"), nil
+	})
+}
+
+// MultiSecretResolver returns a resolver that tries the resolvers in order
+// and returns the first secret that is found.
+func MultiSecretResolver(resolvers ...SecretResolver) SecretResolver {
+	return SecretResolverFunc(func(ctx context.Context, name string) (string, error) {
+		for _, r := range resolvers {
+			v, err := r.ResolveSecret(ctx, name)
+			if errors.Is(err, ErrSecretNotFound) {
+				continue
+			}
+			return v, err
+		}
+		return "", ErrSecretNotFound
+	})
+}
+
+// secretResolution resolves secret references while a document is decoded
+// and remembers the fields containing resolved secrets, so their values can
+// be masked.
+type secretResolution struct {
+	ctx      context.Context
+	resolver SecretResolver
+	// escapeEnv escapes $ in resolved values as $$, so they are not expanded
+	// as environment variables by EnvExpander.
+	escapeEnv bool
+	fields    []secretField
+	errs      []error
+}
+
+// secretField is a field that contains a resolved secret.
+type secretField struct {
+	line   int
+	column int
+	// value is the value of the field after secrets are resolved, before it
+	// is escaped for EnvExpander.
+	value string
+}
+
+// at returns true if the field is positioned at line and column.
+func (f secretField) at(line, column int) bool {
+	return f.line == line && f.column == column
+}
+
+// decoderHook returns the hook that resolves secrets, or nil if s is nil.
+func (s *secretResolution) decoderHook() yaml.DecoderHook {
+	if s == nil {
+		return nil
+	}
+	return s.hook
+}
+
+// hook resolves scalars tagged with SecretTag and secret references embedded
+// in strings.
+func (s *secretResolution) hook(path []string, node *yaml.Node) {
+	if node.Kind != yaml.ScalarNode {
+		return
+	}
+	switch node.Tag {
+	case SecretTag:
+		if v, ok := s.resolve(path, node, node.Value); ok {
+			node.SetString(s.escape(v))
+			s.record(node, v)
+		}
+	case "!!str":
+		if !strings.Contains(node.Value, "${secret:") {
+			return
+		}
+		// the raw value is recorded for masking, the node gets the escaped one
+		var raw, escaped strings.Builder
+		resolved, last := false, 0
+		for _, m := range secretRefRegex.FindAllStringSubmatchIndex(node.Value, -1) {
+			raw.WriteString(node.Value[last:m[0]])
+			escaped.WriteString(node.Value[last:m[0]])
+			last = m[1]
+			ref, name := node.Value[m[0]:m[1]], node.Value[m[2]:m[3]]
+			v, ok := s.resolve(path, node, name)
+			if !ok {
+				raw.WriteString(ref)
+				escaped.WriteString(ref)
+				continue
+			}
+			raw.WriteString(v)
+			escaped.WriteString(s.escape(v))
+			resolved = true
+		}
+		raw.WriteString(node.Value[last:])
+		escaped.WriteString(node.Value[last:])
+		if resolved {
+			node.SetString(escaped.String())
+			s.record(node, raw.String())
+		}
+	}
+}
+
+// record remembers that the field at the position of node contains the
+// resolved secret value.
+func (s *secretResolution) record(node *yaml.Node, value string) {
+	if value != "" {
+		s.fields = append(s.fields, secretField{line: node.Line, column: node.Column, value: value})
+	}
+}
+
+func (s *secretResolution) resolve(path []string, node *yaml.Node, name string) (string, bool) {
+	v, err := s.resolver.ResolveSecret(s.ctx, name)
+	if err != nil {
+		s.errs = append(s.errs, errorAt(node, path, fmt.Errorf("%d:%d: field %s: failed to resolve secret %q: %w", node.Line, node.Column, strings.Join(path, "."), name, err)))
+		return "", false
+	}
+	return v, true
+}
+
+// escape escapes $ in v as $$ if resolved values are expanded by EnvExpander
+// afterwards, so the expansion restores the original value.
+func (s *secretResolution) escape(v string) string {
+	if !s.escapeEnv {
+		return v
+	}
+	return strings.ReplaceAll(v, "$", "$$")
+}
+
+// err returns the errors that occurred while resolving secrets.
+func (s *secretResolution) err() error {
+	return errors.Join(s.errs...)
+}
+
+// field returns the field containing a resolved secret that is positioned at
+// line and column or has the value value.
+func (s *secretResolution) field(line, column int, value string) (secretField, bool) {
+	for _, f := range s.fields {
+		if f.at(line, column) || (value != "" && f.value == value) {
+			return f, true
+		}
+	}
+	return secretField{}, false
+}
+
+// maskWarnings masks the values of fields containing resolved secrets in the
+// warnings about those fields and in the field positions carried by the
+// context. Warnings are matched by their position or value, other warnings
+// are left untouched. Messages are only masked where the value is a whole
+// token, so short values don't mangle them (e.g. "1" in "version 2.1").
+func (s *secretResolution) maskWarnings(warnings evolviconf.Warnings) {
+	if len(s.fields) == 0 {
+		return
+	}
+	for i, w := range warnings {
+		f, ok := s.field(w.Line, w.Column, w.Value)
+		if !ok {
+			continue
+		}
+		warnings[i].Message = evolviconf.MaskValue(w.Message, f.value, SecretMask)
+		if w.Value != "" {
+			warnings[i].Value = SecretMask
+		}
+	}
+	positions := evolviconf.FieldPositionsFromContext(s.ctx)
+	for k, pos := range positions {
+		if _, ok := s.field(pos.Line, pos.Column, pos.Value); ok {
+			pos.Value = SecretMask
+			positions[k] = pos
+		}
+	}
+}
+
+// maskErr masks the values of fields containing resolved secrets in err.
+// Errors about values that can't be decoded are matched by their position,
+// in other errors only quoted values are masked, the position of the error is
+// kept (see ErrorPosition). It returns err if s is nil or nothing needs to be
+// masked.
+func (s *secretResolution) maskErr(err error) error {
+	if s == nil || err == nil || len(s.fields) == 0 {
+		return err
+	}
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		masked := &yaml.TypeError{Errors: make([]yaml.UnmarshalError, len(typeErr.Errors))}
+		for i, uerr := range typeErr.Errors {
+			masked.Errors[i] = uerr
+			var invalid *yaml.InvalidTypeError
+			if errors.As(uerr, &invalid) {
+				if _, ok := s.field(invalid.Line(), invalid.Column(), invalid.Value()); ok {
+					masked.Errors[i] = yaml.NewInvalidTypeError(invalid.Line(), invalid.Column(), invalid.Tag(), SecretMask, invalid.Type())
+				}
+			}
+		}
+		return masked
+	}
+
+	msg := err.Error()
+	for _, f := range s.fields {
+		for _, quoted := range []string{"`" + f.value + "`", strconv.Quote(f.value), "'" + f.value + "'"} {
+			msg = strings.ReplaceAll(msg, quoted, SecretMask)
+		}
+	}
+	if msg == err.Error() {
+		return err
+	}
+	if pos, ok := ErrorPosition(err); ok {
+		// keep the position, the masked error can't wrap err
+		return &positionError{pos: pos, err: errors.New(msg)}
+	}
+	return errors.New(msg)
+}
@@ -1,9 +1,9 @@
 module github.com/conduitio/evolviconf/examples
 
-go 1.23.3
+go 1.24.2
 
 require (
-	github.com/Masterminds/semver/v3 v3.3.1
+	github.com/Masterminds/semver/v3 v3.4.0
 	github.com/conduitio/evolviconf v0.0.0-20241105144803-b3ba81765197
 	github.com/conduitio/evolviconf/evolviyaml v0.0.0-20241105144803-b3ba81765197
 	github.com/conduitio/yaml/v3 v3.3.0
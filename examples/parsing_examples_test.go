@@ -52,7 +52,7 @@ port: 8080
 authToken: "abc"`)
 
 	// Output:
-	// level=WARN msg="authToken is a field introduced in 1.1" line=5 column=1 field=authToken value=abc
+	// level=WARN msg="authToken is a field introduced in 1.1" line=5 column=1 field=authToken value=******
 	// {Host:localhost Port:8080}
 }
 
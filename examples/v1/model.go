@@ -45,7 +45,7 @@ type YAMLConfiguration struct {
 	Version   string `yaml:"version"`
 	Host      string `yaml:"host"`
 	Port      string `yaml:"port"`
-	AuthToken string `yaml:"authToken"`
+	AuthToken string `yaml:"authToken" evolvi:"sensitive"`
 }
 
 // ToConfig needs to be implemented so that EvolviConf can convert the YAML representation
@@ -0,0 +1,177 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"reflect"
+	"strings"
+)
+
+// typeField is a single field of a struct as seen by a decoder that uses the
+// struct tag with the configured key (e.g. "yaml" or "json").
+type typeField struct {
+	// Name is the name of the field in the configuration file.
+	Name   string
+	Index  []int
+	Type   reflect.Type
+	Struct reflect.StructField
+}
+
+// structFields returns the fields of struct type t, named after the struct
+// tag with the given key. Fields without a tag are named like the decoder
+// would name them (lowercase for yaml, unchanged for everything else). Fields
+// tagged with "-" and unexported fields are skipped, inlined fields are
+// flattened into the parent.
+func structFields(t reflect.Type, tag string) []typeField {
+	t = indirectType(t)
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fields []typeField
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		if !sf.IsExported() && !sf.Anonymous {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(sf.Tag.Get(tag), ",")
+		if name == "-" {
+			continue
+		}
+
+		inline := hasTagOption(opts, "inline") || (sf.Anonymous && name == "" && tag != "yaml")
+		if inline {
+			for _, f := range structFields(sf.Type, tag) {
+				f.Index = append([]int{i}, f.Index...)
+				fields = append(fields, f)
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+			if tag == "yaml" {
+				name = strings.ToLower(sf.Name)
+			}
+		}
+		fields = append(fields, typeField{
+			Name:   name,
+			Index:  []int{i},
+			Type:   sf.Type,
+			Struct: sf,
+		})
+	}
+	return fields
+}
+
+// hasTagOption reports whether the comma separated tag options contain opt.
+func hasTagOption(opts, opt string) bool {
+	for opts != "" {
+		var o string
+		o, opts, _ = strings.Cut(opts, ",")
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// indirectType dereferences pointer types until it reaches a non-pointer type.
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// containerElem returns the element type of maps, slices and arrays, which
+// are represented with a wildcard ("*") in field paths. The second return
+// value is false if t is not a container type.
+func containerElem(t reflect.Type) (reflect.Type, bool) {
+	t = indirectType(t)
+	switch t.Kind() { //nolint:exhaustive // only containers are relevant
+	case reflect.Map, reflect.Slice, reflect.Array:
+		return t.Elem(), true
+	default:
+		return nil, false
+	}
+}
+
+// fieldByPath resolves the field path (tokens separated by dots) in type t.
+// Wildcards ("*") match elements of maps, slices and arrays. It returns the
+// type of the last token and true if the path exists.
+func fieldByPath(t reflect.Type, tag string, path []string) (reflect.Type, bool) {
+	for _, token := range path {
+		if token == "*" {
+			elem, ok := containerElem(t)
+			if !ok {
+				return nil, false
+			}
+			t = elem
+			continue
+		}
+
+		var found bool
+		for _, f := range structFields(t, tag) {
+			if f.Name == token {
+				t, found = f.Type, true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return t, true
+}
+
+// FieldNames returns the names of the fields of the struct found at path in
+// type t. Field names are taken from the struct tags with key tag (e.g.
+// "yaml" or "json"). Tokens in the path that point into a map, slice or array
+// are treated as element keys, so both wildcards ("*") and concrete keys or
+// indices can be used. It returns nil if the path can not be resolved to a
+// struct.
+func FieldNames(t reflect.Type, tag string, path []string) []string {
+	for _, token := range path {
+		if elem, ok := containerElem(t); ok {
+			t = elem
+			continue
+		}
+		var found bool
+		for _, f := range structFields(t, tag) {
+			if f.Name == token {
+				t, found = f.Type, true
+				break
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+
+	fields := structFields(t, tag)
+	if len(fields) == 0 {
+		return nil
+	}
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.Name
+	}
+	return names
+}
@@ -0,0 +1,212 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// JSONSchemaDialect is the JSON Schema dialect produced by GenerateJSONSchema.
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema is a subset of a JSON Schema document, it contains the keywords
+// produced by GenerateJSONSchema.
+type JSONSchema struct {
+	Schema      string                 `json:"$schema,omitempty"`
+	Title       string                 `json:"title,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Type        string                 `json:"type,omitempty"`
+	Properties  map[string]*JSONSchema `json:"properties,omitempty"`
+	// AdditionalProperties is either a *JSONSchema describing the values of a
+	// map or false for structs that don't allow unknown fields.
+	AdditionalProperties any         `json:"additionalProperties,omitempty"`
+	Items                *JSONSchema `json:"items,omitempty"`
+	Enum                 []any       `json:"enum,omitempty"`
+	Default              any         `json:"default,omitempty"`
+	Deprecated           bool        `json:"deprecated,omitempty"`
+}
+
+// GenerateJSONSchema generates a JSON Schema for version of the versioned
+// config type t. Field names are taken from the struct tags with key tag
+// (e.g. "yaml" or "json"). The changelog is used to adapt the schema to the
+// version:
+//   - Fields introduced in a newer version are omitted.
+//   - Fields deprecated in this or an older version are marked as deprecated
+//     and the change message is used as the description.
+//   - Defaults in effect in the version (see ChangelogIndex.Defaults) are
+//     added as default values.
+//
+// Allowed values of a field can be specified with the enum key in the struct
+// tag TagKey (e.g. `evolvi:"enum=running|stopped"`).
+func GenerateJSONSchema(t reflect.Type, tag string, version *semver.Version, changelog *ChangelogIndex) (*JSONSchema, error) {
+	g := jsonSchemaGenerator{
+		tag:      tag,
+		rules:    changelog.Rules(version),
+		defaults: changelog.Defaults(version),
+	}
+	schema, err := g.schema(t, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	schema.Schema = JSONSchemaDialect
+	schema.Title = fmt.Sprintf("%s version %s", indirectType(t).Name(), changelog.Scheme().FormatVersion(version))
+	return schema, nil
+}
+
+type jsonSchemaGenerator struct {
+	tag      string
+	rules    map[string]any
+	defaults map[string]Change
+}
+
+func (g jsonSchemaGenerator) schema(t reflect.Type, path []string, seen []reflect.Type) (*JSONSchema, error) {
+	t = indirectType(t)
+
+	switch t.Kind() { //nolint:exhaustive // unsupported kinds produce an empty schema
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}, nil
+	case reflect.String:
+		return &JSONSchema{Type: "string"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := g.schema(t.Elem(), append(slices.Clip(path), "*"), seen)
+		if err != nil {
+			return nil, err
+		}
+		return &JSONSchema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		values, err := g.schema(t.Elem(), append(slices.Clip(path), "*"), seen)
+		if err != nil {
+			return nil, err
+		}
+		return &JSONSchema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		if slices.Contains(seen, t) {
+			// recursive type, allow anything instead of recursing forever
+			return &JSONSchema{Type: "object"}, nil
+		}
+		return g.structSchema(t, path, append(seen, t))
+	default:
+		return &JSONSchema{}, nil
+	}
+}
+
+func (g jsonSchemaGenerator) structSchema(t reflect.Type, path []string, seen []reflect.Type) (*JSONSchema, error) {
+	schema := &JSONSchema{
+		Type:                 "object",
+		Properties:           make(map[string]*JSONSchema),
+		AdditionalProperties: false,
+	}
+
+	var errs []error
+	for _, f := range structFields(t, g.tag) {
+		fieldPath := append(slices.Clip(path), f.Name)
+
+		change, ok := FindChange(g.rules, fieldPath)
+		if ok && change.ChangeType == FieldIntroduced {
+			// field does not exist in this version
+			continue
+		}
+
+		fs, err := g.schema(f.Type, fieldPath, seen)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if ok && change.ChangeType == FieldDeprecated {
+			fs.Deprecated = true
+			fs.Description = change.Message
+		}
+		if err := g.applyTag(fs, f); err != nil {
+			errs = append(errs, fmt.Errorf("field %s: %w", strings.Join(fieldPath, "."), err))
+			continue
+		}
+		if c, ok := g.defaults[strings.Join(fieldPath, ".")]; ok {
+			typ := fs.Type
+			if indirectType(f.Type) == durationType {
+				// durations are written as strings (e.g. "1s")
+				typ = "string"
+			}
+			if fs.Default, err = g.jsonValue("default", typ, c.Default); err != nil {
+				errs = append(errs, fmt.Errorf("field %s: %w", strings.Join(fieldPath, "."), err))
+				continue
+			}
+		}
+		schema.Properties[f.Name] = fs
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return schema, nil
+}
+
+// applyTag applies the keywords found in the struct tag TagKey to the schema.
+func (g jsonSchemaGenerator) applyTag(schema *JSONSchema, f typeField) error {
+	raw, ok := f.Struct.Tag.Lookup(TagKey)
+	if !ok {
+		return nil
+	}
+	pairs, err := parseTag(raw)
+	if err != nil {
+		return err
+	}
+	for _, p := range pairs {
+		if p.key != "enum" {
+			continue
+		}
+		for _, v := range strings.Split(p.value, "|") {
+			ev, err := g.jsonValue("enum", schema.Type, v)
+			if err != nil {
+				return err
+			}
+			schema.Enum = append(schema.Enum, ev)
+		}
+	}
+	return nil
+}
+
+// jsonValue converts the enum or default value to the JSON type of the
+// field.
+func (g jsonSchemaGenerator) jsonValue(kind, typ, v string) (any, error) {
+	var (
+		out any
+		err error
+	)
+	switch typ {
+	case "integer":
+		out, err = strconv.ParseInt(v, 10, 64)
+	case "number":
+		out, err = strconv.ParseFloat(v, 64)
+	case "boolean":
+		out, err = strconv.ParseBool(v)
+	default:
+		out = v
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s value %q for type %s: %w", kind, v, typ, err)
+	}
+	return out, nil
+}
@@ -0,0 +1,195 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/matryer/is"
+)
+
+type schemaTestProcessor struct {
+	Type      string            `yaml:"type" evolvi:"deprecated=1.2,msg=please use plugin"`
+	Plugin    string            `yaml:"plugin" evolvi:"introduced=1.2"`
+	Workers   int               `yaml:"workers" evolvi:"enum=1|2|4"`
+	Settings  map[string]string `yaml:"settings"`
+	Enabled   *bool             `yaml:"enabled"`
+	Threshold float64           `yaml:"threshold"`
+}
+
+type schemaTestConfig struct {
+	Version    string                `yaml:"version"`
+	Status     string                `yaml:"status" evolvi:"enum=running|stopped"`
+	Processors []schemaTestProcessor `yaml:"processors"`
+	Anything   any                   `yaml:"anything"`
+}
+
+func TestGenerateJSONSchema(t *testing.T) {
+	changelog, err := ChangelogFromTags(reflect.TypeFor[schemaTestConfig](), "yaml", semver.MustParse("1.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		version string
+		want    string
+	}{{
+		version: "1.0",
+		want: `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "schemaTestConfig version 1.0",
+  "type": "object",
+  "properties": {
+    "anything": {},
+    "processors": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "enabled": {
+            "type": "boolean"
+          },
+          "settings": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "string"
+            }
+          },
+          "threshold": {
+            "type": "number"
+          },
+          "type": {
+            "type": "string"
+          },
+          "workers": {
+            "type": "integer",
+            "enum": [
+              1,
+              2,
+              4
+            ]
+          }
+        },
+        "additionalProperties": false
+      }
+    },
+    "status": {
+      "type": "string",
+      "enum": [
+        "running",
+        "stopped"
+      ]
+    },
+    "version": {
+      "type": "string"
+    }
+  },
+  "additionalProperties": false
+}`,
+	}, {
+		version: "1.2",
+		want: `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "schemaTestConfig version 1.2",
+  "type": "object",
+  "properties": {
+    "anything": {},
+    "processors": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "enabled": {
+            "type": "boolean"
+          },
+          "plugin": {
+            "type": "string"
+          },
+          "settings": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "string"
+            }
+          },
+          "threshold": {
+            "type": "number"
+          },
+          "type": {
+            "description": "please use plugin",
+            "type": "string",
+            "deprecated": true
+          },
+          "workers": {
+            "type": "integer",
+            "enum": [
+              1,
+              2,
+              4
+            ]
+          }
+        },
+        "additionalProperties": false
+      }
+    },
+    "status": {
+      "type": "string",
+      "enum": [
+        "running",
+        "stopped"
+      ]
+    },
+    "version": {
+      "type": "string"
+    }
+  },
+  "additionalProperties": false
+}`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.version, func(t *testing.T) {
+			is := is.New(t)
+			schema, err := GenerateJSONSchema(
+				reflect.TypeFor[schemaTestConfig](),
+				"yaml",
+				semver.MustParse(tc.version),
+				changelog.Index(),
+			)
+			is.NoErr(err)
+
+			got, err := json.MarshalIndent(schema, "", "  ")
+			is.NoErr(err)
+			is.Equal(string(got), tc.want)
+		})
+	}
+}
+
+func TestGenerateJSONSchema_InvalidEnum(t *testing.T) {
+	is := is.New(t)
+
+	type config struct {
+		Workers int `yaml:"workers" evolvi:"enum=one|two"`
+	}
+	_, err := GenerateJSONSchema(
+		reflect.TypeFor[config](),
+		"yaml",
+		semver.MustParse("1.0"),
+		NewChangelogIndex(),
+	)
+	is.Equal(err.Error(), `field workers: invalid enum value "one" for type integer: strconv.ParseInt: parsing "one": invalid syntax`)
+}
@@ -0,0 +1,127 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// JUnitTestCase is a test case in a JUnit report, usually containing the
+// warnings of a single file or document.
+type JUnitTestCase struct {
+	Name     string
+	Warnings Warnings
+}
+
+// WriteJUnit writes a JUnit XML report with a single test suite named suite
+// containing the test cases. A test case fails if it contains at least one
+// warning with SeverityError, all other warnings are reported as output of the
+// test case.
+func WriteJUnit(out io.Writer, suite string, cases ...JUnitTestCase) error {
+	report := junitTestSuites{
+		Tests: len(cases),
+		Suites: []junitTestSuite{{
+			Name:  suite,
+			Tests: len(cases),
+			Cases: make([]junitTestCase, len(cases)),
+		}},
+	}
+
+	for i, c := range cases {
+		tc := junitTestCase{
+			Name:      c.Name,
+			ClassName: suite,
+		}
+
+		var errs, warns []string
+		for _, w := range c.Warnings {
+			if w.Severity == SeverityError {
+				errs = append(errs, w.junitLine())
+			} else {
+				warns = append(warns, w.junitLine())
+			}
+		}
+		if len(errs) > 0 {
+			tc.Failure = &junitFailure{
+				Message: fmt.Sprintf("%d error(s)", len(errs)),
+				Type:    SeverityError.String(),
+				Text:    strings.Join(errs, "\n"),
+			}
+			report.Failures++
+			report.Suites[0].Failures++
+		}
+		if len(warns) > 0 {
+			tc.SystemOut = strings.Join(warns, "\n")
+		}
+		report.Suites[0].Cases[i] = tc
+	}
+
+	if _, err := io.WriteString(out, xml.Header); err != nil {
+		return fmt.Errorf("failed to write JUnit report: %w", err)
+	}
+	enc := xml.NewEncoder(out)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return fmt.Errorf("failed to write JUnit report: %w", err)
+	}
+	if _, err := io.WriteString(out, "\n"); err != nil {
+		return fmt.Errorf("failed to write JUnit report: %w", err)
+	}
+	return nil
+}
+
+// WriteJUnit writes the warnings as a JUnit XML report containing a single
+// test case with the supplied name (e.g. the file name). See WriteJUnit.
+func (w Warnings) WriteJUnit(out io.Writer, name string) error {
+	return WriteJUnit(out, "evolviconf", JUnitTestCase{Name: name, Warnings: w})
+}
+
+// junitLine formats the warning as a single line in the JUnit report.
+func (w Warning) junitLine() string {
+	if w.Code == "" {
+		return w.String()
+	}
+	return fmt.Sprintf("%s (%s)", w.String(), w.Code)
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
@@ -0,0 +1,271 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// KindVersionParser parses the kind and version of the next document. The
+// returned version is nil if the document doesn't specify a version. It
+// returns ErrKindNotSpecified if the document doesn't specify a kind.
+type KindVersionParser[D any] interface {
+	ParseKindVersion(ctx context.Context, decoder D) (string, *semver.Version, error)
+}
+
+// Object is a config parsed by Mux.
+type Object struct {
+	Kind    string
+	Version *semver.Version
+	// Config is the config returned by the versioned config parser
+	// registered for Kind.
+	Config any
+}
+
+// Mux parses streams that contain documents of different kinds (e.g.
+// pipelines and connectors). It dispatches each document to the versioned
+// config parsers registered for the kind of the document with RegisterKind,
+// and picks the parser based on the version like Parser.
+type Mux[D any] struct {
+	decoderProvider  DecoderProvider[D]
+	kindParser       KindVersionParser[D]
+	kinds            map[string]*Parser[any, D]
+	versionResolvers []VersionResolver
+	versionPolicy    VersionPolicy
+	// versionLifecycles contains the version lifecycles per kind.
+	versionLifecycles map[string][]VersionLifecycle
+	now               func() time.Time
+	versionScheme     VersionScheme
+}
+
+func NewMux[D any](
+	decoderProvider DecoderProvider[D],
+	kindParser KindVersionParser[D],
+) *Mux[D] {
+	return &Mux[D]{
+		decoderProvider: decoderProvider,
+		kindParser:      kindParser,
+		kinds:           make(map[string]*Parser[any, D]),
+
+		versionLifecycles: make(map[string][]VersionLifecycle),
+		now:               time.Now,
+		versionScheme:     SemverScheme,
+	}
+}
+
+// RegisterKind registers the versioned config parsers for documents of the
+// supplied kind. Each kind has its own set of versions, parsers registered
+// for an already registered kind replace the previous ones.
+func RegisterKind[T, D any](m *Mux[D], kind string, parsers ...VersionedConfigParser[T, D]) *Mux[D] {
+	configParsers := make([]VersionedConfigParser[any, D], len(parsers))
+	for i, p := range parsers {
+		configParsers[i] = anyConfigParser[T, D]{p}
+	}
+	m.kinds[kind] = NewParserExtended[any, D](m.decoderProvider, nil, configParsers...).
+		WithVersionResolvers(m.versionResolvers...).
+		WithVersionPolicy(m.versionPolicy).
+		WithVersionLifecycles(m.versionLifecycles[kind]...).
+		WithClock(m.now).
+		WithVersionScheme(m.versionScheme)
+	return m
+}
+
+// WithVersionResolvers sets the resolvers used for documents without a
+// version, see Parser.WithVersionResolvers.
+func (m *Mux[D]) WithVersionResolvers(resolvers ...VersionResolver) *Mux[D] {
+	m.versionResolvers = resolvers
+	for _, p := range m.kinds {
+		p.WithVersionResolvers(resolvers...)
+	}
+	return m
+}
+
+// WithVersionPolicy sets the version policy used for all kinds, see
+// Parser.WithVersionPolicy.
+func (m *Mux[D]) WithVersionPolicy(policy VersionPolicy) *Mux[D] {
+	m.versionPolicy = policy
+	for _, p := range m.kinds {
+		p.WithVersionPolicy(policy)
+	}
+	return m
+}
+
+// WithVersionLifecycles marks versions of the supplied kind as deprecated or
+// unsupported, see Parser.WithVersionLifecycles.
+func (m *Mux[D]) WithVersionLifecycles(kind string, lifecycles ...VersionLifecycle) *Mux[D] {
+	m.versionLifecycles[kind] = lifecycles
+	if p, ok := m.kinds[kind]; ok {
+		p.WithVersionLifecycles(lifecycles...)
+	}
+	return m
+}
+
+// WithClock sets the function used to get the current time, see
+// Parser.WithClock.
+func (m *Mux[D]) WithClock(now func() time.Time) *Mux[D] {
+	m.now = now
+	for _, p := range m.kinds {
+		p.WithClock(now)
+	}
+	return m
+}
+
+// WithVersionScheme sets the scheme used to parse, compare and format
+// versions of all kinds, see Parser.WithVersionScheme. The scheme is also
+// passed on to the kind parser if it implements VersionSchemeSetter.
+func (m *Mux[D]) WithVersionScheme(scheme VersionScheme) *Mux[D] {
+	m.versionScheme = scheme
+	setVersionScheme(scheme, m.decoderProvider, m.kindParser)
+	for _, p := range m.kinds {
+		p.WithVersionScheme(scheme)
+	}
+	return m
+}
+
+// Kinds returns the registered kinds in sorted order.
+func (m *Mux[D]) Kinds() []string {
+	kinds := make([]string, 0, len(m.kinds))
+	for k := range m.kinds {
+		kinds = append(kinds, k)
+	}
+	slices.Sort(kinds)
+	return kinds
+}
+
+// Parse parses all documents in reader and returns them in order.
+func (m *Mux[D]) Parse(ctx context.Context, reader io.Reader) ([]Object, Warnings, error) {
+	reader, src, err := readSource(ctx, reader, len(m.versionResolvers) > 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// see Parser.Parse
+	var buffer bytes.Buffer
+	reader = io.TeeReader(reader, &buffer)
+
+	kindDecoder := m.decoderProvider.Decoder(reader)
+	configurationDecoder := m.decoderProvider.Decoder(&buffer)
+
+	var objects []Object
+	var warnings Warnings
+
+	for {
+		kind, version, err := m.kindParser.ParseKindVersion(ctx, kindDecoder)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, nil, fmt.Errorf("failed to parse kind and version: %w", err)
+		}
+
+		parser, ok := m.kinds[kind]
+		if !ok {
+			return nil, nil, fmt.Errorf("unsupported kind %q", kind)
+		}
+
+		if version == nil {
+			var w Warnings
+			version, w, err = parser.missingVersion(ctx, src)
+			if err != nil {
+				return nil, nil, err
+			}
+			warnings = append(warnings, w...)
+		}
+
+		config, w, err := parser.parseDocument(ctx, configurationDecoder, version)
+		if err != nil {
+			return nil, nil, fmt.Errorf("kind %q: %w", kind, err)
+		}
+		warnings = append(warnings, w...)
+
+		objects = append(objects, Object{
+			Kind:    kind,
+			Version: version,
+			Config:  config,
+		})
+	}
+
+	return objects, warnings, nil
+}
+
+// Bucket returns the configs of all objects with a config of type T, in
+// order.
+func Bucket[T any](objects []Object) []T {
+	var out []T
+	for _, o := range objects {
+		if c, ok := o.Config.(T); ok {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// anyConfigParser wraps a VersionedConfigParser, so that parsers with
+// different config types can be stored in Mux.
+type anyConfigParser[T, D any] struct {
+	VersionedConfigParser[T, D]
+}
+
+func (p anyConfigParser[T, D]) ParseVersionedConfig(ctx context.Context, decoder D, version *semver.Version) (VersionedConfig[any], Warnings, error) {
+	config, warnings, err := p.VersionedConfigParser.ParseVersionedConfig(ctx, decoder, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	return anyConfig[T]{config}, warnings, nil
+}
+
+// AcceptsPrerelease forwards to the wrapped parser if it implements
+// PrereleaseAcceptor.
+func (p anyConfigParser[T, D]) AcceptsPrerelease() bool {
+	if a, ok := p.VersionedConfigParser.(PrereleaseAcceptor); ok {
+		return a.AcceptsPrerelease()
+	}
+	return false
+}
+
+// OldestKnownVersion forwards to the wrapped parser if it implements
+// OldestKnownVersioner.
+func (p anyConfigParser[T, D]) OldestKnownVersion() *semver.Version {
+	if o, ok := p.VersionedConfigParser.(OldestKnownVersioner); ok {
+		return o.OldestKnownVersion()
+	}
+	return nil
+}
+
+// SetVersionScheme forwards to the wrapped parser if it implements
+// VersionSchemeSetter.
+func (p anyConfigParser[T, D]) SetVersionScheme(scheme VersionScheme) {
+	setVersionScheme(scheme, p.VersionedConfigParser)
+}
+
+type anyConfig[T any] struct {
+	VersionedConfig[T]
+}
+
+func (c anyConfig[T]) ToConfig() (any, error) {
+	return c.VersionedConfig.ToConfig()
+}
+
+func (c anyConfig[T]) ToConfigContext(ctx context.Context) (any, Warnings, error) {
+	return toConfig(ctx, c.VersionedConfig)
+}
@@ -0,0 +1,174 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/matryer/is"
+)
+
+// testConnector is a second config type used to test Mux.
+type testConnector struct {
+	Kind   string `json:"kind"`
+	Plugin string `json:"plugin"`
+}
+
+func (c testConnector) ToConfig() (testConnector, error) {
+	return c, nil
+}
+
+type testConnectorParser struct{}
+
+func (testConnectorParser) LatestKnownVersion() *semver.Version {
+	return semver.MustParse("3.0")
+}
+
+func (testConnectorParser) Constraint() VersionConstraint {
+	c, _ := semver.NewConstraint("^3")
+	return c
+}
+
+func (testConnectorParser) ParseVersionedConfig(_ context.Context, dec *json.Decoder, _ *semver.Version) (VersionedConfig[testConnector], Warnings, error) {
+	var cfg testConnector
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, nil, err
+	}
+	return cfg, Warnings{{Message: "connector parsed"}}, nil
+}
+
+// testKindParser parses the kind and version of JSON documents.
+type testKindParser struct{}
+
+func (testKindParser) Decoder(r io.Reader) *json.Decoder {
+	return json.NewDecoder(r)
+}
+
+func (testKindParser) ParseKindVersion(_ context.Context, dec *json.Decoder) (string, *semver.Version, error) {
+	var doc struct {
+		Kind    string `json:"kind"`
+		Version string `json:"version"`
+	}
+	if err := dec.Decode(&doc); err != nil {
+		return "", nil, err
+	}
+	if doc.Kind == "" {
+		return "", nil, ErrKindNotSpecified
+	}
+	if doc.Version == "" {
+		return doc.Kind, nil, nil
+	}
+	v, err := semver.NewVersion(doc.Version)
+	return doc.Kind, v, err
+}
+
+func newTestMux() *Mux[*json.Decoder] {
+	mux := NewMux[*json.Decoder](testKindParser{}, testKindParser{})
+	RegisterKind[testConfig](mux, "pipeline", newTestParser("^1", "1.1"), newTestParser("^2", "2.0"))
+	RegisterKind[testConnector](mux, "connector", testConnectorParser{})
+	return mux
+}
+
+func TestMux_Parse(t *testing.T) {
+	is := is.New(t)
+	mux := newTestMux()
+	is.Equal(mux.Kinds(), []string{"connector", "pipeline"})
+
+	objects, warnings, err := mux.Parse(context.Background(), strings.NewReader(`
+{"kind":"pipeline","version":"1.0","name":"p1"}
+{"kind":"connector","version":"3.0","plugin":"builtin:file"}
+{"kind":"pipeline","version":"2.0","name":"p2"}
+{"kind":"connector","plugin":"builtin:s3"}
+`))
+	is.NoErr(err)
+
+	kinds := make([]string, len(objects))
+	versions := make([]string, len(objects))
+	for i, o := range objects {
+		kinds[i] = o.Kind
+		versions[i] = o.Version.String()
+	}
+	is.Equal(kinds, []string{"pipeline", "connector", "pipeline", "connector"})
+	// versions are resolved per kind, the missing connector version falls
+	// back to the latest connector version
+	is.Equal(versions, []string{"1.0.0", "3.0.0", "2.0.0", "3.0.0"})
+
+	is.Equal(Bucket[testConfig](objects), []testConfig{
+		{Version: "1.0", Name: "p1", ParsedVersion: "1.0.0"},
+		{Version: "2.0", Name: "p2", ParsedVersion: "2.0.0"},
+	})
+	is.Equal(Bucket[testConnector](objects), []testConnector{
+		{Kind: "connector", Plugin: "builtin:file"},
+		{Kind: "connector", Plugin: "builtin:s3"},
+	})
+
+	is.Equal(warnings, Warnings{
+		{Message: "connector parsed"},
+		{Message: "no version defined, falling back to parser version 3.0", Code: CodeVersionNotSpecified},
+		{Message: "connector parsed"},
+	})
+}
+
+func TestMux_Parse_Errors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		src     string
+		wantErr string
+	}{{
+		name:    "unknown kind",
+		src:     `{"kind":"processor","version":"1.0"}`,
+		wantErr: `unsupported kind "processor"`,
+	}, {
+		name:    "missing kind",
+		src:     `{"version":"1.0"}`,
+		wantErr: "failed to parse kind and version: kind not specified",
+	}, {
+		name:    "unsupported version of kind",
+		src:     `{"kind":"connector","version":"1.0"}`,
+		wantErr: `kind "connector": unsupported version 1.0`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			_, _, err := newTestMux().Parse(context.Background(), strings.NewReader(tc.src))
+			is.True(err != nil)
+			is.Equal(err.Error(), tc.wantErr)
+		})
+	}
+
+	_, _, err := newTestMux().Parse(context.Background(), strings.NewReader(`{}`))
+	is.New(t).True(errors.Is(err, ErrKindNotSpecified))
+}
+
+func TestMux_WithVersionResolvers(t *testing.T) {
+	is := is.New(t)
+	mux := newTestMux().WithVersionResolvers(ContextVersionResolver())
+	// kinds registered after setting the resolvers use them as well
+	RegisterKind[testConfig](mux, "other", newTestParser("^1", "1.1"))
+
+	ctx := ContextWithDefaultVersion(context.Background(), semver.MustParse("1.0"))
+	objects, warnings, err := mux.Parse(ctx, strings.NewReader(`{"kind":"pipeline"}{"kind":"other"}`))
+	is.NoErr(err)
+	is.Equal(len(warnings), 0)
+	is.Equal(objects[0].Version.String(), "1.0.0")
+	is.Equal(objects[1].Version.String(), "1.0.0")
+}
@@ -15,11 +15,14 @@
 package evolviconf
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"slices"
+	"time"
 
 	"github.com/Masterminds/semver/v3"
 )
@@ -34,7 +37,7 @@ type VersionParser[D any] interface {
 
 type VersionedConfigParser[T, D any] interface {
 	LatestKnownVersion() *semver.Version
-	Constraint() *semver.Constraints
+	Constraint() VersionConstraint
 	ParseVersionedConfig(ctx context.Context, decoder D, version *semver.Version) (VersionedConfig[T], Warnings, error)
 }
 
@@ -53,6 +56,20 @@ type Parser[T, D any] struct {
 	versionParser   VersionParser[D]
 	configParsers   []VersionedConfigParser[T, D]
 	latestVersion   *semver.Version
+	// oldestVersion is the oldest version known by any of the parsers.
+	oldestVersion *semver.Version
+	// versionResolvers resolve the version of documents without a version.
+	versionResolvers []VersionResolver
+	versionPolicy    VersionPolicy
+	// versionLifecycles mark versions as deprecated or unsupported.
+	versionLifecycles []VersionLifecycle
+	// now returns the current time, it is used to check sunset dates.
+	now func() time.Time
+	// versionScheme is used to compare versions and to format them in
+	// messages.
+	versionScheme VersionScheme
+	// encoderProvider is used by Encode.
+	encoderProvider EncoderProvider
 }
 
 func NewParser[T, D any](
@@ -80,22 +97,121 @@ func NewParserExtended[T, D any](
 	versionParser VersionParser[D],
 	configParsers ...VersionedConfigParser[T, D],
 ) *Parser[T, D] {
+	encoderProvider, _ := decoderProvider.(EncoderProvider)
+
+	p := &Parser[T, D]{
+		decoderProvider: decoderProvider,
+		versionParser:   versionParser,
+		configParsers:   configParsers,
+		now:             time.Now,
+		versionScheme:   SemverScheme,
+		encoderProvider: encoderProvider,
+	}
+	p.initKnownVersions()
+	return p
+}
+
+// initKnownVersions sets the latest and oldest version known by any of the
+// config parsers, compared with the version scheme of the parser.
+func (p *Parser[T, D]) initKnownVersions() {
 	latestVersion := semver.MustParse("0.0.0")
-	for _, parser := range configParsers {
-		if parser.LatestKnownVersion().GreaterThan(latestVersion) {
+	var oldestVersion *semver.Version
+	for _, parser := range p.configParsers {
+		if p.versionScheme.Compare(parser.LatestKnownVersion(), latestVersion) > 0 {
 			latestVersion = parser.LatestKnownVersion()
 		}
+		oldest := parser.LatestKnownVersion()
+		if o, ok := parser.(OldestKnownVersioner); ok && o.OldestKnownVersion() != nil {
+			oldest = o.OldestKnownVersion()
+		}
+		if oldestVersion == nil || p.versionScheme.Compare(oldest, oldestVersion) < 0 {
+			oldestVersion = oldest
+		}
+	}
+	if oldestVersion == nil {
+		oldestVersion = latestVersion
+	}
+	p.latestVersion = latestVersion
+	p.oldestVersion = oldestVersion
+}
+
+// WithVersionResolvers sets the resolvers that are used to determine the
+// version of documents that don't specify a version. The resolvers are
+// consulted in order, if none of them resolves the version the parser falls
+// back to the latest known version. Resolvers implementing VersionSchemeSetter
+// get the version scheme of the parser.
+func (p *Parser[T, D]) WithVersionResolvers(resolvers ...VersionResolver) *Parser[T, D] {
+	p.versionResolvers = resolvers
+	for _, r := range resolvers {
+		setVersionScheme(p.versionScheme, r)
 	}
+	return p
+}
 
-	return &Parser[T, D]{
-		decoderProvider: decoderProvider,
-		versionParser:   versionParser,
-		configParsers:   configParsers,
-		latestVersion:   latestVersion,
+// WithVersionPolicy sets the policy that defines how documents without a
+// version and documents with versions newer than the latest known version are
+// handled. The default policy falls back to the latest known version and
+// parses newer versions with the best matching parser.
+func (p *Parser[T, D]) WithVersionPolicy(policy VersionPolicy) *Parser[T, D] {
+	p.versionPolicy = policy
+	return p
+}
+
+// WithVersionLifecycles marks versions as deprecated or unsupported. Documents
+// with a version that matches the constraint of a lifecycle produce a warning
+// or an error, if multiple lifecycles match the first one is used.
+func (p *Parser[T, D]) WithVersionLifecycles(lifecycles ...VersionLifecycle) *Parser[T, D] {
+	p.versionLifecycles = lifecycles
+	return p
+}
+
+// WithClock sets the function used to get the current time when checking the
+// sunset date of deprecated versions. Defaults to time.Now.
+func (p *Parser[T, D]) WithClock(now func() time.Time) *Parser[T, D] {
+	p.now = now
+	return p
+}
+
+// WithVersionScheme sets the scheme used to parse, compare and format
+// versions. Defaults to SemverScheme. The scheme is passed on to the decoder
+// provider, the version parser, the versioned config parsers and the version
+// resolvers that implement VersionSchemeSetter, so it only needs to be
+// configured here.
+func (p *Parser[T, D]) WithVersionScheme(scheme VersionScheme) *Parser[T, D] {
+	p.versionScheme = scheme
+	setVersionScheme(scheme, p.decoderProvider, p.versionParser)
+	for _, parser := range p.configParsers {
+		setVersionScheme(scheme, parser)
+	}
+	for _, r := range p.versionResolvers {
+		setVersionScheme(scheme, r)
+	}
+	p.initKnownVersions()
+	return p
+}
+
+// VersionScheme returns the version scheme of the parser.
+func (p *Parser[T, D]) VersionScheme() VersionScheme {
+	return p.versionScheme
+}
+
+// setVersionScheme passes the scheme to the values implementing
+// VersionSchemeSetter. Setting the scheme is idempotent, a value passed
+// multiple times (e.g. an AllInOneParser) is simply set again.
+func setVersionScheme(scheme VersionScheme, values ...any) {
+	for _, v := range values {
+		if s, ok := v.(VersionSchemeSetter); ok {
+			s.SetVersionScheme(scheme)
+		}
 	}
 }
 
 func (p *Parser[T, D]) Parse(ctx context.Context, reader io.Reader) ([]T, Warnings, error) {
+	reader, src, err := readSource(ctx, reader, len(p.versionResolvers) > 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// we redirect everything read from reader to buffer with TeeReader, so that
 	// we can first parse the version of the file and choose what type we
 	// actually need to parse the configuration
@@ -109,7 +225,7 @@ func (p *Parser[T, D]) Parse(ctx context.Context, reader io.Reader) ([]T, Warnin
 	var warnings Warnings
 
 	for {
-		version, w, err := p.parseVersion(ctx, versionDecoder)
+		version, w, err := p.parseVersion(ctx, versionDecoder, src)
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				break
@@ -118,42 +234,120 @@ func (p *Parser[T, D]) Parse(ctx context.Context, reader io.Reader) ([]T, Warnin
 		}
 		warnings = append(warnings, w...)
 
-		parser, perfectMatch := p.findVersionedConfigParser(version)
-		if parser == nil {
-			return nil, nil, fmt.Errorf("unsupported version %s", version)
+		out, w, err := p.parseDocument(ctx, configurationDecoder, version)
+		if err != nil {
+			return nil, nil, err
 		}
+		warnings = append(warnings, w...)
 
-		if !perfectMatch {
-			warnings = append(warnings, Warning{
-				Message: fmt.Sprintf("no parser found for version %s, using parser for version %s with costraints %s", version, parser.LatestKnownVersion(), parser.Constraint()),
-			})
-		}
+		configs = append(configs, out)
+	}
 
-		config, w, err := parser.ParseVersionedConfig(ctx, configurationDecoder, version)
-		if err != nil {
-			return nil, nil, fmt.Errorf("failed to parse versioned config: %w", err)
-		}
-		warnings = append(warnings, w.Sort()...)
+	return configs, warnings, nil
+}
 
-		out, err := config.ToConfig()
-		if err != nil {
-			return nil, nil, fmt.Errorf("failed to convert versioned config to actual config: %w", err)
+// readSource returns the source of the reader. If header is true, the first
+// bytes of the reader are peeked and stored in Source.Header, the returned
+// reader must be used instead of the original reader in that case.
+func readSource(ctx context.Context, reader io.Reader, header bool) (io.Reader, Source, error) {
+	src := Source{Name: SourceNameFromContext(ctx)}
+	if !header {
+		return reader, src, nil
+	}
+
+	br := bufio.NewReaderSize(reader, maxSourceHeaderSize)
+	h, err := br.Peek(maxSourceHeaderSize)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, Source{}, fmt.Errorf("failed to read source: %w", err)
+	}
+	src.Header = bytes.Clone(h)
+	return br, src, nil
+}
+
+// parseDocument parses the next document from the decoder with the versioned
+// config parser that matches the version and converts it to the config.
+func (p *Parser[T, D]) parseDocument(ctx context.Context, decoder D, version *semver.Version) (T, Warnings, error) {
+	var zero T
+
+	warnings, err := p.versionPolicy.checkPrerelease(version, p.versionScheme)
+	if err != nil {
+		return zero, nil, err
+	}
+
+	w, err := p.checkLifecycle(version)
+	if err != nil {
+		return zero, nil, err
+	}
+	warnings = append(warnings, w...)
+
+	parser, perfectMatch := p.findVersionedConfigParser(version)
+	if parser == nil {
+		return zero, nil, fmt.Errorf("%w %s", ErrUnsupportedVersion, p.formatVersion(version))
+	}
+
+	if !perfectMatch {
+		if p.versionPolicy.rejectsNewer(version, parser.LatestKnownVersion(), p.versionScheme) {
+			return zero, nil, fmt.Errorf("%w %s, latest known version is %s (newer version policy: %s)", ErrUnsupportedVersion, p.formatVersion(version), p.formatVersion(parser.LatestKnownVersion()), p.versionPolicy.Newer)
 		}
+		msg := fmt.Sprintf("no parser found for version %s, using parser for version %s with costraints %s", p.formatVersion(version), p.formatVersion(parser.LatestKnownVersion()), parser.Constraint())
+		if p.versionPolicy.Newer != NewerVersionWarn {
+			msg += fmt.Sprintf(" (newer version policy: %s)", p.versionPolicy.Newer)
+		}
+		warnings = append(warnings, Warning{
+			Code:    CodeVersionFallback,
+			Message: msg,
+		})
+	}
 
-		configs = append(configs, out)
+	// the versioned config parser can record the positions of fields, which
+	// are used by FieldWarning when converting the versioned config
+	ctx = ContextWithFieldPositions(ctx, make(FieldPositions))
+
+	config, configWarnings, err := parser.ParseVersionedConfig(ctx, decoder, version)
+	if err != nil {
+		return zero, nil, fmt.Errorf("failed to parse versioned config: %w", err)
 	}
 
-	return configs, warnings, nil
+	out, w, err := toConfig(ctx, config)
+	if err != nil {
+		return zero, nil, fmt.Errorf("failed to convert versioned config to actual config: %w", err)
+	}
+	configWarnings = mergeWarnings(configWarnings, w)
+	return out, append(warnings, configWarnings.Sort()...), nil
+}
+
+// checkLifecycle returns the warnings for the lifecycle of the version, or an
+// error if the version is not supported anymore.
+func (p *Parser[T, D]) checkLifecycle(version *semver.Version) (Warnings, error) {
+	for _, l := range p.versionLifecycles {
+		if l.Constraint.Check(version) {
+			return l.check(p.formatVersion(version), p.now(), p.versionPolicy.Sunset)
+		}
+	}
+	return nil, nil
 }
 
-func (p *Parser[T, D]) parseVersion(ctx context.Context, decoder D) (*semver.Version, Warnings, error) {
+// mergeWarnings appends the warnings produced when converting the versioned
+// config to the warnings produced when parsing it. Conversion warnings with
+// the same position and code as a parser warning are dropped, as they report
+// the same issue.
+func mergeWarnings(parsed, converted Warnings) Warnings {
+	for _, w := range converted {
+		duplicate := w.Line > 0 && slices.ContainsFunc(parsed, func(p Warning) bool {
+			return p.Line == w.Line && p.Column == w.Column && p.Code == w.Code
+		})
+		if !duplicate {
+			parsed = append(parsed, w)
+		}
+	}
+	return parsed
+}
+
+func (p *Parser[T, D]) parseVersion(ctx context.Context, decoder D, src Source) (*semver.Version, Warnings, error) {
 	version, err := p.versionParser.ParseVersion(ctx, decoder)
 	if err != nil {
 		if errors.Is(err, ErrVersionNotSpecified) {
-			// No version specified, fall back to the latest known version.
-			return p.latestVersion, Warnings{{
-				Message: "no version defined, falling back to parser version " + p.latestVersion.String(),
-			}}, nil
+			return p.missingVersion(ctx, src)
 		}
 		return nil, nil, fmt.Errorf("failed to parse version: %w", err)
 	}
@@ -161,23 +355,93 @@ func (p *Parser[T, D]) parseVersion(ctx context.Context, decoder D) (*semver.Ver
 	return version, nil, nil
 }
 
+// missingVersion returns the version used for documents that don't specify
+// a version.
+func (p *Parser[T, D]) missingVersion(ctx context.Context, src Source) (*semver.Version, Warnings, error) {
+	version, err := p.resolveVersion(ctx, src)
+	if err != nil {
+		return nil, nil, err
+	}
+	if version != nil {
+		return version, nil, nil
+	}
+
+	policy := p.versionPolicy.Missing
+	switch policy {
+	case MissingVersionLatest:
+		// No version specified, fall back to the latest known version.
+		return p.latestVersion, Warnings{{
+			Message: "no version defined, falling back to parser version " + p.formatVersion(p.latestVersion),
+			Code:    CodeVersionNotSpecified,
+		}}, nil
+	case MissingVersionOldest:
+		return p.oldestVersion, Warnings{{
+			Message: fmt.Sprintf("no version defined, using oldest known version %s (missing version policy: %s)", p.formatVersion(p.oldestVersion), policy),
+			Code:    CodeVersionNotSpecified,
+		}}, nil
+	case MissingVersionPinned:
+		if p.versionPolicy.Pinned == nil {
+			return nil, nil, fmt.Errorf("missing version policy %s requires a pinned version", policy)
+		}
+		return p.versionPolicy.Pinned, Warnings{{
+			Message: fmt.Sprintf("no version defined, using pinned version %s (missing version policy: %s)", p.formatVersion(p.versionPolicy.Pinned), policy),
+			Code:    CodeVersionNotSpecified,
+		}}, nil
+	case MissingVersionReject:
+		return nil, nil, fmt.Errorf("%w (missing version policy: %s)", ErrVersionNotSpecified, policy)
+	default:
+		return nil, nil, fmt.Errorf("unknown missing version policy %s", policy)
+	}
+}
+
+// resolveVersion returns the version resolved by the first version resolver
+// that can resolve it, or nil.
+func (p *Parser[T, D]) resolveVersion(ctx context.Context, src Source) (*semver.Version, error) {
+	for _, r := range p.versionResolvers {
+		version, err := r.ResolveVersion(ctx, src)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve version: %w", err)
+		}
+		if version != nil {
+			return version, nil
+		}
+	}
+	return nil, nil
+}
+
+// formatVersion formats the version with the version scheme of the parser.
+func (p *Parser[T, D]) formatVersion(v *semver.Version) string {
+	return p.versionScheme.FormatVersion(v)
+}
+
 // findVersionedConfigParser returns the versioned config parser for the version
 // and a boolean denoting if it's a perfect match. If it's not a perfect match,
 // the best possible match is returned.
 func (p *Parser[T, D]) findVersionedConfigParser(version *semver.Version) (VersionedConfigParser[T, D], bool) {
-	var bestMatch VersionedConfigParser[T, D]
-	for _, parser := range p.configParsers {
-		if parser.Constraint().Check(version) {
-			if parser.LatestKnownVersion().GreaterThanEqual(version) {
+	i, perfectMatch := p.findVersionedConfigParserIndex(version)
+	if i < 0 {
+		return nil, false
+	}
+	return p.configParsers[i], perfectMatch
+}
+
+// findVersionedConfigParserIndex returns the index of the versioned config
+// parser for the version in configParsers, see findVersionedConfigParser. If
+// no parser matches, -1 is returned.
+func (p *Parser[T, D]) findVersionedConfigParserIndex(version *semver.Version) (int, bool) {
+	bestMatch := -1
+	for i, parser := range p.configParsers {
+		if CheckConstraint(parser, parser.Constraint(), version) {
+			if p.versionScheme.Compare(parser.LatestKnownVersion(), version) >= 0 {
 				// This is a perfect match.
-				return parser, true
+				return i, true
 			}
 
 			// The constraint is satisfied, but the latest known version is smaller,
 			// store this as the best match and continue searching if there is a
 			// better parser in the list.
-			if bestMatch == nil || bestMatch.LatestKnownVersion().LessThan(parser.LatestKnownVersion()) {
-				bestMatch = parser
+			if bestMatch < 0 || p.versionScheme.Compare(p.configParsers[bestMatch].LatestKnownVersion(), parser.LatestKnownVersion()) < 0 {
+				bestMatch = i
 			}
 		}
 	}
@@ -0,0 +1,246 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/matryer/is"
+)
+
+// testConfig is a config parsed by testParser. It is both the versioned
+// config and the final config.
+type testConfig struct {
+	Version string `json:"version"`
+	Name    string `json:"name"`
+	// ParsedVersion is the version the config was parsed with.
+	ParsedVersion string `json:"-"`
+}
+
+func (c testConfig) ToConfig() (testConfig, error) {
+	return c, nil
+}
+
+// testParser parses a stream of JSON documents containing testConfig.
+type testParser struct {
+	constraint VersionConstraint
+	latest     *semver.Version
+	oldest     *semver.Version
+	prerelease bool
+	// scheme is used to parse versions, it is set by
+	// Parser.WithVersionScheme.
+	scheme VersionScheme
+}
+
+func newTestParser(constraint, latest string) testParser {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		panic(err)
+	}
+	return testParser{
+		constraint: c,
+		latest:     semver.MustParse(latest),
+	}
+}
+
+func (p testParser) Decoder(r io.Reader) *json.Decoder {
+	return json.NewDecoder(r)
+}
+
+func (p testParser) ParseVersion(_ context.Context, dec *json.Decoder) (*semver.Version, error) {
+	var doc struct {
+		Version string `json:"version"`
+	}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if doc.Version == "" {
+		return nil, ErrVersionNotSpecified
+	}
+	if p.scheme != nil {
+		return p.scheme.ParseVersion(doc.Version)
+	}
+	return semver.NewVersion(doc.Version)
+}
+
+func (p testParser) LatestKnownVersion() *semver.Version {
+	return p.latest
+}
+
+func (p testParser) OldestKnownVersion() *semver.Version {
+	return p.oldest
+}
+
+func (p *testParser) SetVersionScheme(scheme VersionScheme) {
+	p.scheme = scheme
+}
+
+func (p testParser) AcceptsPrerelease() bool {
+	return p.prerelease
+}
+
+func (p testParser) Constraint() VersionConstraint {
+	return p.constraint
+}
+
+func (p testParser) ParseVersionedConfig(_ context.Context, dec *json.Decoder, version *semver.Version) (VersionedConfig[testConfig], Warnings, error) {
+	var cfg testConfig
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, nil, err
+	}
+	cfg.ParsedVersion = version.String()
+	return cfg, nil, nil
+}
+
+func TestParser_Parse(t *testing.T) {
+	is := is.New(t)
+	parser := NewParser[testConfig, *json.Decoder](newTestParser("^1", "1.1"))
+
+	got, warnings, err := parser.Parse(context.Background(), strings.NewReader(
+		`{"version":"1.0","name":"a"}{"name":"b"}{"version":"1.2","name":"c"}`,
+	))
+	is.NoErr(err)
+	is.Equal(got, []testConfig{
+		{Version: "1.0", Name: "a", ParsedVersion: "1.0.0"},
+		{Name: "b", ParsedVersion: "1.1.0"},
+		{Version: "1.2", Name: "c", ParsedVersion: "1.2.0"},
+	})
+	is.Equal(warnings, Warnings{{
+		Message: "no version defined, falling back to parser version 1.1",
+		Code:    CodeVersionNotSpecified,
+	}, {
+		Message: "no parser found for version 1.2, using parser for version 1.1 with costraints ^1",
+		Code:    CodeVersionFallback,
+	}})
+
+	_, _, err = parser.Parse(context.Background(), strings.NewReader(`{"version":"2.0"}`))
+	is.Equal(err.Error(), "unsupported version 2.0")
+}
+
+func TestParser_VersionResolvers(t *testing.T) {
+	parser := NewParser[testConfig, *json.Decoder](newTestParser("^1", "1.2")).
+		WithVersionResolvers(
+			FileNameVersionResolver(regexp.MustCompile(`\.v([\w.]+)\.json$`)),
+			SourceDefaultVersionResolver(map[string]*semver.Version{
+				"legacy.json": semver.MustParse("1.0"),
+			}),
+			ContextVersionResolver(),
+		)
+
+	testCases := []struct {
+		name    string
+		ctx     context.Context
+		want    string
+		wantErr bool
+		// wantFallback is true if the parser falls back to the latest
+		// version and warns about the missing version
+		wantFallback bool
+	}{{
+		name: "file name",
+		ctx:  ContextWithSourceName(context.Background(), "/tmp/pipelines.v1.1.json"),
+		want: "1.1.0",
+	}, {
+		name:    "invalid version in file name",
+		ctx:     ContextWithSourceName(context.Background(), "pipelines.vx.json"),
+		wantErr: true,
+	}, {
+		name: "source default",
+		ctx:  ContextWithSourceName(context.Background(), "legacy.json"),
+		want: "1.0.0",
+	}, {
+		name: "context default",
+		ctx:  ContextWithDefaultVersion(context.Background(), semver.MustParse("1.1")),
+		want: "1.1.0",
+	}, {
+		name:         "fallback to latest",
+		ctx:          context.Background(),
+		want:         "1.2.0",
+		wantFallback: true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+
+			// the version parsed from the document takes precedence
+			got, warnings, err := parser.Parse(tc.ctx, strings.NewReader(`{"version":"1.2"}{"name":"a"}`))
+			if tc.wantErr {
+				is.True(err != nil)
+				return
+			}
+			is.NoErr(err)
+			is.Equal(got[0].ParsedVersion, "1.2.0")
+			is.Equal(got[1].ParsedVersion, tc.want)
+			if tc.wantFallback {
+				is.Equal(len(warnings), 1)
+				is.Equal(warnings[0].Code, CodeVersionNotSpecified)
+				return
+			}
+			is.Equal(len(warnings), 0)
+		})
+	}
+}
+
+func TestCommentVersionResolver(t *testing.T) {
+	resolver := CommentVersionResolver("config-version")
+
+	testCases := []struct {
+		name    string
+		header  string
+		want    string
+		wantErr bool
+	}{{
+		name:   "yaml comment",
+		header: "# some comment\n#config-version: 2.1\nversion: 1.0\n",
+		want:   "2.1.0",
+	}, {
+		name:   "slash comment",
+		header: "\n// config-version: 1\n{}",
+		want:   "1.0.0",
+	}, {
+		name:   "comment after content",
+		header: "version: 1.0\n# config-version: 2.1\n",
+	}, {
+		name:   "other key",
+		header: "# version: 2.1\n",
+	}, {
+		name:    "invalid version",
+		header:  "# config-version: latest\n",
+		wantErr: true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			got, err := resolver.ResolveVersion(context.Background(), Source{Header: []byte(tc.header)})
+			if tc.wantErr {
+				is.True(err != nil)
+				return
+			}
+			is.NoErr(err)
+			if tc.want == "" {
+				is.Equal(got, nil)
+				return
+			}
+			is.Equal(got.String(), tc.want)
+		})
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"path"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// RedactedValue replaces sensitive values in warnings.
+const RedactedValue = "******"
+
+// Redactor decides which fields contain sensitive values and redacts them in
+// warnings. A nil Redactor doesn't redact anything.
+type Redactor struct {
+	patterns [][]string
+}
+
+// NewRedactor creates a redactor for fields matching the supplied patterns.
+// Patterns are field paths with tokens separated by dots (e.g.
+// "pipelines.*.connectors.*.settings.*token*"). Each token is matched against
+// a single token of the field path using path.Match, ignoring case, so "*"
+// matches any single token and "*token*" matches tokens containing "token".
+// The token "**" matches any number of tokens (e.g. "**.password"). A
+// pattern also matches all children of the fields it matches. Tokens that
+// are not valid patterns never match.
+func NewRedactor(patterns ...string) *Redactor {
+	return (*Redactor)(nil).With(patterns...)
+}
+
+// With returns a new redactor that matches the patterns of r and the
+// supplied patterns, see NewRedactor.
+func (r *Redactor) With(patterns ...string) *Redactor {
+	out := &Redactor{}
+	if r != nil {
+		out.patterns = slices.Clone(r.patterns)
+	}
+	for _, p := range patterns {
+		out.patterns = append(out.patterns, strings.Split(strings.ToLower(p), "."))
+	}
+	return out
+}
+
+// Sensitive returns true if the field at path or one of its parents matches
+// a pattern of the redactor.
+func (r *Redactor) Sensitive(path []string) bool {
+	if r == nil || len(path) == 0 {
+		return false
+	}
+	lower := make([]string, len(path))
+	for i, t := range path {
+		lower[i] = strings.ToLower(t)
+	}
+	for _, p := range r.patterns {
+		if matchPathPrefix(p, lower) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactWarning returns the warning with its value replaced by RedactedValue
+// if the field at path is sensitive. Occurrences of the value in the message
+// are redacted as well (see MaskValue).
+func (r *Redactor) RedactWarning(w Warning, path []string) Warning {
+	if w.Value == "" || !r.Sensitive(path) {
+		return w
+	}
+	w.Message = MaskValue(w.Message, w.Value, RedactedValue)
+	w.Value = RedactedValue
+	return w
+}
+
+// MaskValue replaces the occurrences of value in s with mask, unless they are
+// part of a longer token (e.g. a word or a version), so short values don't
+// mangle unrelated text (e.g. "1" in "version 1.1").
+func MaskValue(s, value, mask string) string {
+	if value == "" {
+		return s
+	}
+	var b strings.Builder
+	for start := 0; ; {
+		i := strings.Index(s[start:], value)
+		if i < 0 {
+			b.WriteString(s[start:])
+			return b.String()
+		}
+		i += start
+		end := i + len(value)
+		b.WriteString(s[start:i])
+		if partOfToken(s, i-1, -1) || partOfToken(s, end, 1) {
+			b.WriteString(value)
+		} else {
+			b.WriteString(mask)
+		}
+		start = end
+	}
+}
+
+// partOfToken returns true if the character at index i in s continues the
+// token next to it, dir is -1 if the token follows i and 1 if it precedes i.
+// Dots and dashes only continue a token if they are followed by a letter or
+// digit in the same direction (e.g. "2.1" but not "js.").
+func partOfToken(s string, i, dir int) bool {
+	if i < 0 || i >= len(s) {
+		return false
+	}
+	switch c := s[i]; {
+	case c == '_' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)):
+		return true
+	case c == '.' || c == '-':
+		return partOfToken(s, i+dir, dir) && s[i+dir] != '.' && s[i+dir] != '-'
+	default:
+		return c >= utf8.RuneSelf
+	}
+}
+
+// matchPathPrefix reports whether the pattern tokens match a prefix of the
+// path tokens.
+func matchPathPrefix(pattern, tokens []string) bool {
+	if len(pattern) == 0 {
+		return true
+	}
+	if pattern[0] == "**" {
+		return matchPathPrefix(pattern[1:], tokens) ||
+			(len(tokens) > 0 && matchPathPrefix(pattern, tokens[1:]))
+	}
+	if len(tokens) == 0 {
+		return false
+	}
+	if ok, err := path.Match(pattern[0], tokens[0]); err != nil || !ok {
+		return false
+	}
+	return matchPathPrefix(pattern[1:], tokens[1:])
+}
+
+// SensitiveFields returns the paths of the fields in type t that are marked
+// as sensitive with the struct tag TagKey (e.g. `evolvi:"sensitive"`). Field
+// paths are built from the struct tags with key tag (e.g. "yaml" or "json"),
+// maps, slices and arrays are represented with a wildcard ("*"). The paths
+// can be used as patterns in NewRedactor.
+func SensitiveFields(t reflect.Type, tag string) []string {
+	var fields []string
+	walkTypeFields(t, tag, func(path []string, f typeField) {
+		raw, ok := f.Struct.Tag.Lookup(TagKey)
+		if !ok {
+			return
+		}
+		pairs, err := parseTag(raw)
+		if err != nil {
+			return // reported by ChangelogFromTags
+		}
+		if slices.ContainsFunc(pairs, func(p tagPair) bool { return p.key == "sensitive" }) {
+			fields = append(fields, strings.Join(path, "."))
+		}
+	})
+	return fields
+}
@@ -0,0 +1,162 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestRedactor_Sensitive(t *testing.T) {
+	r := NewRedactor(
+		"pipelines.*.connectors.*.settings.*secret*",
+		"**.authToken",
+		"pipelines.*.dead-letter-queue.settings",
+		"[invalid",
+	)
+
+	testCases := []struct {
+		path string
+		want bool
+	}{
+		{path: "pipelines.0.connectors.1.settings.aws.secretAccessKey", want: true},
+		{path: "pipelines.0.connectors.1.settings.aws.region", want: false},
+		{path: "authToken", want: true},
+		{path: "pipelines.0.AUTHTOKEN", want: true},
+		{path: "pipelines.0.authTokenName", want: false},
+		// children of matching fields are sensitive as well
+		{path: "pipelines.0.dead-letter-queue.settings.token", want: true},
+		{path: "pipelines.0.dead-letter-queue.plugin", want: false},
+		{path: "[invalid", want: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			// split on the first dots only, settings keys can contain dots
+			path := strings.SplitN(tc.path, ".", 6)
+			is.New(t).Equal(r.Sensitive(path), tc.want)
+		})
+	}
+
+	is := is.New(t)
+	var nilRedactor *Redactor
+	is.True(!nilRedactor.Sensitive([]string{"authToken"}))
+	is.True(nilRedactor.With("authToken").Sensitive([]string{"authToken"}))
+}
+
+func TestRedactor_RedactWarning(t *testing.T) {
+	is := is.New(t)
+	r := NewRedactor("authToken")
+
+	w := Warning{
+		Position: Position{Field: "authToken", Line: 5, Column: 1, Value: "abc"},
+		Message:  "invalid token abc",
+	}
+	got := r.RedactWarning(w, []string{"authToken"})
+	is.Equal(got.Value, RedactedValue)
+	is.Equal(got.Message, "invalid token "+RedactedValue)
+	// the original warning is not modified
+	is.Equal(w.Value, "abc")
+
+	is.Equal(r.RedactWarning(w, []string{"host"}), w)
+
+	// short values only redact whole tokens
+	w = Warning{
+		Position: Position{Field: "authToken", Value: "t"},
+		Message:  "authToken is a field introduced in 1.1, got t",
+	}
+	is.Equal(r.RedactWarning(w, []string{"authToken"}).Message, "authToken is a field introduced in 1.1, got "+RedactedValue)
+	w = Warning{
+		Position: Position{Field: "authToken", Value: "1"},
+		Message:  "authToken is a field introduced in 1.1",
+	}
+	is.Equal(r.RedactWarning(w, []string{"authToken"}).Message, "authToken is a field introduced in 1.1")
+}
+
+func TestMaskValue(t *testing.T) {
+	testCases := []struct {
+		s, value string
+		want     string
+	}{
+		{s: "invalid token abc", value: "abc", want: "invalid token ***"},
+		{s: "abc, abc and abcd", value: "abc", want: "***, *** and abcd"},
+		{s: "value `js`.", value: "js", want: "value `***`."},
+		{s: "authToken is a field introduced in 1.1", value: "t", want: "authToken is a field introduced in 1.1"},
+		{s: "t is not a valid token", value: "t", want: "*** is not a valid token"},
+		{s: "1.1 and 1", value: "1", want: "1.1 and ***"},
+		{s: "my-key and key", value: "key", want: "my-key and ***"},
+		{s: "key: schlüssel", value: "schl", want: "key: schlüssel"},
+		{s: "empty value", value: "", want: "empty value"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.s, func(t *testing.T) {
+			is.New(t).Equal(MaskValue(tc.s, tc.value, "***"), tc.want)
+		})
+	}
+}
+
+func TestSensitiveFields(t *testing.T) {
+	is := is.New(t)
+	type connector struct {
+		Settings map[string]string `yaml:"settings" evolvi:"sensitive"`
+	}
+	type config struct {
+		Token      string      `yaml:"token" evolvi:"sensitive,introduced=1.1"`
+		Host       string      `yaml:"host"`
+		Connectors []connector `yaml:"connectors"`
+	}
+
+	is.Equal(SensitiveFields(reflect.TypeFor[config](), "yaml"), []string{"token", "connectors.*.settings"})
+
+	cl, err := ChangelogFromTags(reflect.TypeFor[config](), "yaml")
+	is.NoErr(err)
+	is.Equal(len(cl), 1) // sensitive is not part of the changelog
+}
+
+func TestWarnings_Log_RedactedValues(t *testing.T) {
+	is := is.New(t)
+	var out bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	warnings := Warnings{{
+		Position: Position{Field: "authToken", Line: 5, Column: 1, Value: "abc"},
+		Message:  "field authToken with value abc is unknown",
+	}}
+	warnings.Log(context.Background(), logger, LogRedactedValues())
+	is.Equal(out.String(), `level=WARN msg="field authToken with value ****** is unknown" line=5 column=1 field=authToken value=******`+"\n")
+
+	// short values only redact whole tokens
+	out.Reset()
+	warnings = Warnings{{
+		Position: Position{Field: "workers", Line: 1, Column: 1, Value: "1"},
+		Message:  "field workers was introduced in 1.1",
+	}}
+	warnings.Log(context.Background(), logger, LogRedactedValues())
+	is.Equal(out.String(), `level=WARN msg="field workers was introduced in 1.1" line=1 column=1 field=workers value=******`+"\n")
+}
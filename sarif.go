@@ -0,0 +1,260 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+
+	// sarifDefaultRuleID is the rule ID of warnings without a code.
+	sarifDefaultRuleID = "warning"
+)
+
+// sarifRuleDescriptions contains the descriptions of the rules for known
+// warning codes.
+var sarifRuleDescriptions = map[string]string{
+	CodeUnknownField:        "Field is not known in the config version",
+	CodeFieldIntroduced:     "Field was introduced in a newer config version",
+	CodeFieldDeprecated:     "Field is deprecated",
+	CodeVersionNotSpecified: "Config version is not specified",
+	CodeVersionFallback:     "Config version is not supported by any parser",
+	CodeVersionDeprecated:   "Config version is deprecated",
+	CodeVersionPrerelease:   "Config version is a pre-release",
+	CodeFieldLost:           "Field is lost when converting to an older config version",
+	CodeDefaultChanged:      "Default value of a field changes in a newer config version",
+	CodeEnvVarNotSet:        "Environment variable is not set",
+	CodeEnvVarInvalid:       "Environment variable reference is malformed",
+	sarifDefaultRuleID:      "Config warning",
+}
+
+// SARIFOptions configure the SARIF log written by Warnings.WriteSARIF.
+type SARIFOptions struct {
+	// ToolName is the name of the tool in the log (defaults to "evolviconf").
+	ToolName string
+	// ToolVersion is the optional version of the tool.
+	ToolVersion string
+	// InformationURI is an optional URI with information about the tool.
+	InformationURI string
+	// FileName is the name of the file the warnings belong to. Warnings that
+	// refer to another file (see Position.File) use that file instead.
+	// Results only contain locations if a file is known.
+	FileName string
+}
+
+// WriteSARIF writes the warnings as a SARIF 2.1.0 log with a single run. The
+// rule ID of each result is the warning code, the location is the position of
+// the warning in the file of the warning (see Position.File) or
+// opts.FileName. Fixes are included as SARIF fixes.
+func (w Warnings) WriteSARIF(out io.Writer, opts SARIFOptions) error {
+	if opts.ToolName == "" {
+		opts.ToolName = "evolviconf"
+	}
+
+	driver := sarifDriver{
+		Name:           opts.ToolName,
+		Version:        opts.ToolVersion,
+		InformationURI: opts.InformationURI,
+		Rules:          []sarifRule{},
+	}
+	ruleIndex := make(map[string]int)
+	results := make([]sarifResult, len(w))
+
+	for i, ww := range w {
+		ruleID := ww.Code
+		if ruleID == "" {
+			ruleID = sarifDefaultRuleID
+		}
+		index, ok := ruleIndex[ruleID]
+		if !ok {
+			index = len(driver.Rules)
+			ruleIndex[ruleID] = index
+			rule := sarifRule{ID: ruleID}
+			if desc, ok := sarifRuleDescriptions[ruleID]; ok {
+				rule.ShortDescription = &sarifMessage{Text: desc}
+			}
+			driver.Rules = append(driver.Rules, rule)
+		}
+
+		results[i] = sarifResult{
+			RuleID:    ruleID,
+			RuleIndex: index,
+			Level:     ww.sarifLevel(),
+			Message:   sarifMessage{Text: ww.Message},
+		}
+		fileName := opts.FileName
+		if ww.File != "" {
+			fileName = ww.File
+		}
+		if fileName != "" {
+			results[i].Locations = ww.sarifLocations(fileName)
+			results[i].Fixes = ww.sarifFixes(fileName)
+		}
+	}
+
+	log := sarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs: []sarifRun{{
+			Tool:    sarifTool{Driver: driver},
+			Results: results,
+		}},
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(log); err != nil {
+		return fmt.Errorf("failed to write SARIF log: %w", err)
+	}
+	return nil
+}
+
+func (w Warning) sarifLevel() string {
+	if w.Severity == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+func (w Warning) sarifLocations(fileName string) []sarifLocation {
+	loc := sarifLocation{
+		PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: fileName},
+		},
+	}
+	if w.Line > 0 {
+		// SARIF requires lines and columns to be positive
+		loc.PhysicalLocation.Region = &sarifRegion{StartLine: w.Line}
+		if w.Column > 0 {
+			loc.PhysicalLocation.Region.StartColumn = w.Column
+			if w.Field != "" {
+				loc.PhysicalLocation.Region.EndColumn = w.Column + len(w.Field)
+			}
+		}
+	}
+	return []sarifLocation{loc}
+}
+
+func (w Warning) sarifFixes(fileName string) []sarifFix {
+	if w.Fix == nil {
+		return nil
+	}
+	replacements := make([]sarifReplacement, len(w.Fix.Edits))
+	for i, e := range w.Fix.Edits {
+		replacements[i] = sarifReplacement{
+			DeletedRegion: sarifRegion{
+				StartLine:   e.Range.StartLine,
+				StartColumn: e.Range.StartColumn,
+				EndLine:     e.Range.EndLine,
+				EndColumn:   e.Range.EndColumn,
+			},
+			InsertedContent: &sarifArtifactContent{Text: e.NewText},
+		}
+	}
+	return []sarifFix{{
+		Description: sarifMessage{Text: w.Fix.Message},
+		ArtifactChanges: []sarifArtifactChange{{
+			ArtifactLocation: sarifArtifactLocation{URI: fileName},
+			Replacements:     replacements,
+		}},
+	}}
+}
+
+// The types below contain the subset of the SARIF 2.1.0 format used by
+// WriteSARIF.
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string        `json:"id"`
+	ShortDescription *sarifMessage `json:"shortDescription,omitempty"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+	Fixes     []sarifFix      `json:"fixes,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage          `json:"description"`
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifRegion           `json:"deletedRegion"`
+	InsertedContent *sarifArtifactContent `json:"insertedContent,omitempty"`
+}
+
+type sarifArtifactContent struct {
+	Text string `json:"text"`
+}
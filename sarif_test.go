@@ -0,0 +1,275 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestWarnings_WriteSARIF(t *testing.T) {
+	is := is.New(t)
+
+	warnings := Warnings{{
+		Position: Position{Field: "unknownField", Line: 6, Column: 5},
+		Message:  "field unknownField not found in type v2.Pipeline",
+		Code:     CodeUnknownField,
+	}, {
+		Position: Position{Field: "type", Line: 25, Column: 9, Value: "js"},
+		Message:  "please use field 'plugin'",
+		Code:     CodeFieldDeprecated,
+		Fix: &Fix{
+			Message: "rename field type to plugin",
+			Edits: []TextEdit{{
+				Range:   Range{StartLine: 25, StartColumn: 9, EndLine: 25, EndColumn: 13},
+				NewText: "plugin",
+			}},
+		},
+	}, {
+		Message: "no version defined, falling back to parser version 2.2.0",
+		Code:    CodeVersionNotSpecified,
+	}, {
+		Position: Position{Field: "other", Line: 7, Column: 5},
+		Message:  "field other not found in type v2.Pipeline",
+		Code:     CodeUnknownField,
+	}, {
+		Message: "custom warning",
+	}}
+
+	want := `{
+  "version": "2.1.0",
+  "$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+  "runs": [
+    {
+      "tool": {
+        "driver": {
+          "name": "conduit",
+          "version": "v0.13.0",
+          "rules": [
+            {
+              "id": "unknown-field",
+              "shortDescription": {
+                "text": "Field is not known in the config version"
+              }
+            },
+            {
+              "id": "field-deprecated",
+              "shortDescription": {
+                "text": "Field is deprecated"
+              }
+            },
+            {
+              "id": "version-not-specified",
+              "shortDescription": {
+                "text": "Config version is not specified"
+              }
+            },
+            {
+              "id": "warning",
+              "shortDescription": {
+                "text": "Config warning"
+              }
+            }
+          ]
+        }
+      },
+      "results": [
+        {
+          "ruleId": "unknown-field",
+          "ruleIndex": 0,
+          "level": "warning",
+          "message": {
+            "text": "field unknownField not found in type v2.Pipeline"
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "pipelines.yml"
+                },
+                "region": {
+                  "startLine": 6,
+                  "startColumn": 5,
+                  "endColumn": 17
+                }
+              }
+            }
+          ]
+        },
+        {
+          "ruleId": "field-deprecated",
+          "ruleIndex": 1,
+          "level": "warning",
+          "message": {
+            "text": "please use field 'plugin'"
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "pipelines.yml"
+                },
+                "region": {
+                  "startLine": 25,
+                  "startColumn": 9,
+                  "endColumn": 13
+                }
+              }
+            }
+          ],
+          "fixes": [
+            {
+              "description": {
+                "text": "rename field type to plugin"
+              },
+              "artifactChanges": [
+                {
+                  "artifactLocation": {
+                    "uri": "pipelines.yml"
+                  },
+                  "replacements": [
+                    {
+                      "deletedRegion": {
+                        "startLine": 25,
+                        "startColumn": 9,
+                        "endLine": 25,
+                        "endColumn": 13
+                      },
+                      "insertedContent": {
+                        "text": "plugin"
+                      }
+                    }
+                  ]
+                }
+              ]
+            }
+          ]
+        },
+        {
+          "ruleId": "version-not-specified",
+          "ruleIndex": 2,
+          "level": "warning",
+          "message": {
+            "text": "no version defined, falling back to parser version 2.2.0"
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "pipelines.yml"
+                }
+              }
+            }
+          ]
+        },
+        {
+          "ruleId": "unknown-field",
+          "ruleIndex": 0,
+          "level": "warning",
+          "message": {
+            "text": "field other not found in type v2.Pipeline"
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "pipelines.yml"
+                },
+                "region": {
+                  "startLine": 7,
+                  "startColumn": 5,
+                  "endColumn": 10
+                }
+              }
+            }
+          ]
+        },
+        {
+          "ruleId": "warning",
+          "ruleIndex": 3,
+          "level": "warning",
+          "message": {
+            "text": "custom warning"
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "pipelines.yml"
+                }
+              }
+            }
+          ]
+        }
+      ]
+    }
+  ]
+}
+`
+
+	var out bytes.Buffer
+	err := warnings.WriteSARIF(&out, SARIFOptions{
+		ToolName:    "conduit",
+		ToolVersion: "v0.13.0",
+		FileName:    "pipelines.yml",
+	})
+	is.NoErr(err)
+	is.Equal(out.String(), want)
+}
+
+func TestWarnings_WriteSARIF_Empty(t *testing.T) {
+	is := is.New(t)
+
+	var out bytes.Buffer
+	err := Warnings(nil).WriteSARIF(&out, SARIFOptions{})
+	is.NoErr(err)
+	is.Equal(out.String(), `{
+  "version": "2.1.0",
+  "$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+  "runs": [
+    {
+      "tool": {
+        "driver": {
+          "name": "evolviconf",
+          "rules": []
+        }
+      },
+      "results": []
+    }
+  ]
+}
+`)
+}
+
+func TestWarnings_WriteSARIF_File(t *testing.T) {
+	is := is.New(t)
+
+	warnings := Warnings{{
+		Position: Position{File: "connectors/s3.yml", Field: "type", Line: 3, Column: 1},
+		Message:  "please use field 'plugin'",
+		Code:     CodeFieldDeprecated,
+	}}
+	var out bytes.Buffer
+	err := warnings.WriteSARIF(&out, SARIFOptions{})
+	is.NoErr(err)
+
+	var got sarifLog
+	is.NoErr(json.Unmarshal(out.Bytes(), &got))
+	is.Equal(len(got.Runs[0].Results[0].Locations), 1)
+	is.Equal(got.Runs[0].Results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI, "connectors/s3.yml")
+}
@@ -0,0 +1,257 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// TagKey is the struct tag key used to annotate fields with changelog
+// information, see ChangelogFromTags.
+const TagKey = "evolvi"
+
+// ChangelogFromTags builds a changelog by reflecting over the versioned config
+// type t and reading the struct tags with the key TagKey. Field paths are
+// built from the struct tags with key tag (e.g. "yaml" or "json"), nested
+// maps, slices and arrays are represented with a wildcard ("*"). The
+// supplied versions are added to the changelog even if no field is annotated
+// with them (e.g. the initial version).
+//
+// The tag contains a comma separated list of key=value pairs:
+//   - introduced=<version> records a FieldIntroduced change.
+//   - deprecated=<version> records a FieldDeprecated change.
+//   - replaced-by=<field> sets Change.ReplacedBy of the recorded deprecation.
+//   - default@<version>=<value> records a DefaultChanged change, the default
+//     of the field is value starting with version. Can be repeated for
+//     multiple versions.
+//   - msg=<message> overrides the message of the introduced and deprecated
+//     changes recorded by the tag. The message is everything after "msg=", so
+//     it can contain commas and needs to be the last pair in the tag.
+//   - enum=<value1>|<value2>|... lists the allowed values of the field, it
+//     is not part of the changelog but is used by GenerateJSONSchema.
+//   - sensitive marks the field as sensitive, it is not part of the changelog
+//     but is used by SensitiveFields.
+//
+// Example:
+//
+//	Type      string `yaml:"type" evolvi:"deprecated=2.2,msg=please use plugin"`
+//	Condition string `yaml:"condition" evolvi:"introduced=2.1"`
+//	Window    int    `yaml:"window-size" evolvi:"default@2.0=1,default@2.2=4"`
+func ChangelogFromTags(t reflect.Type, tag string, versions ...*semver.Version) (Changelog, error) {
+	return ChangelogFromTagsWithScheme(t, tag, SemverScheme, versions...)
+}
+
+// ChangelogFromTagsWithScheme is like ChangelogFromTags, but parses the
+// versions in the struct tags with the supplied version scheme (e.g.
+// `evolvi:"introduced=2024-06-01"` with DateScheme).
+func ChangelogFromTagsWithScheme(t reflect.Type, tag string, scheme VersionScheme, versions ...*semver.Version) (Changelog, error) {
+	cl := make(Changelog)
+	// keys maps normalized versions to the version used as key in cl, so that
+	// equal versions end up in the same entry
+	keys := make(map[string]*semver.Version)
+	key := func(v *semver.Version) *semver.Version {
+		n := normalizeVersion(v)
+		if k, ok := keys[n]; ok {
+			return k
+		}
+		keys[n] = v
+		cl[v] = []Change{}
+		return v
+	}
+
+	for _, v := range versions {
+		key(v)
+	}
+
+	var errs []error
+	walkTypeFields(t, tag, func(path []string, f typeField) {
+		raw, ok := f.Struct.Tag.Lookup(TagKey)
+		if !ok {
+			return
+		}
+		field := strings.Join(path, ".")
+		changes, err := parseChangelogTag(field, raw, scheme)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("field %s: %w", field, err))
+			return
+		}
+		for _, c := range changes {
+			k := key(c.version)
+			cl[k] = append(cl[k], c.Change)
+		}
+	})
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return cl, nil
+}
+
+// taggedChange is a change parsed from a struct tag, together with the
+// version it belongs to.
+type taggedChange struct {
+	Change
+	version *semver.Version
+}
+
+// tagPair is a single key=value pair in a struct tag with key TagKey.
+type tagPair struct {
+	key   string
+	value string
+}
+
+// parseTag splits the value of a struct tag with key TagKey into key=value
+// pairs and makes sure all keys are known, see ChangelogFromTags.
+func parseTag(tag string) ([]tagPair, error) {
+	var pairs []tagPair
+	for tag != "" {
+		var pair string
+		if strings.HasPrefix(tag, "msg=") {
+			// message consumes the rest of the tag
+			pair, tag = tag, ""
+		} else {
+			pair, tag, _ = strings.Cut(tag, ",")
+		}
+
+		if pair == "sensitive" {
+			pairs = append(pairs, tagPair{key: pair})
+			continue
+		}
+
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid %s tag %q: expected key=value", TagKey, pair)
+		}
+		switch {
+		case k == "introduced", k == "deprecated", k == "replaced-by", k == "msg", k == "enum":
+			pairs = append(pairs, tagPair{key: k, value: v})
+		case strings.HasPrefix(k, "default@"):
+			if v == "" {
+				return nil, fmt.Errorf("invalid %s tag %q: empty default value", TagKey, pair)
+			}
+			pairs = append(pairs, tagPair{key: k, value: v})
+		default:
+			return nil, fmt.Errorf("invalid %s tag %q: unknown key %q", TagKey, pair, k)
+		}
+	}
+	return pairs, nil
+}
+
+// parseChangelogTag parses the changes from the value of a struct tag with
+// key TagKey, see ChangelogFromTags.
+func parseChangelogTag(field, tag string, scheme VersionScheme) ([]taggedChange, error) {
+	pairs, err := parseTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []taggedChange
+	var msg, replacedBy string
+	for _, p := range pairs {
+		if v, ok := strings.CutPrefix(p.key, "default@"); ok {
+			version, err := scheme.ParseVersion(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s tag \"%s=%s\": %w", TagKey, p.key, p.value, err)
+			}
+			changes = append(changes, taggedChange{
+				Change: Change{
+					Field:      field,
+					ChangeType: DefaultChanged,
+					Default:    p.value,
+				},
+				version: version,
+			})
+			continue
+		}
+
+		var changeType ChangeType
+		switch p.key {
+		case "msg":
+			msg = p.value
+			continue
+		case "replaced-by":
+			replacedBy = p.value
+			continue
+		case "introduced":
+			changeType = FieldIntroduced
+		case "deprecated":
+			changeType = FieldDeprecated
+		default:
+			continue // not related to the changelog
+		}
+
+		version, err := scheme.ParseVersion(p.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s tag \"%s=%s\": %w", TagKey, p.key, p.value, err)
+		}
+		changes = append(changes, taggedChange{
+			Change: Change{
+				Field:      field,
+				ChangeType: changeType,
+				Message:    defaultChangeMessage(field, changeType, p.value),
+			},
+			version: version,
+		})
+	}
+
+	// the first default of the field is not a change of the default
+	var first *taggedChange
+	for i := range changes {
+		c := &changes[i]
+		if c.ChangeType == DefaultChanged && (first == nil || scheme.Compare(c.version, first.version) < 0) {
+			first = c
+		}
+	}
+	for i := range changes {
+		if changes[i].ChangeType == DefaultChanged {
+			changes[i].Message = defaultValueChangeMessage(field, changes[i].Default, scheme.FormatVersion(changes[i].version), &changes[i] == first)
+			continue
+		}
+		if msg != "" {
+			changes[i].Message = msg
+		}
+		if changes[i].ChangeType == FieldDeprecated {
+			changes[i].ReplacedBy = replacedBy
+		}
+	}
+	return changes, nil
+}
+
+// defaultChangeMessage returns the message used for changes that don't
+// specify a message explicitly.
+func defaultChangeMessage(field string, changeType ChangeType, version string) string {
+	switch changeType {
+	case FieldIntroduced:
+		return fmt.Sprintf("field %s was introduced in version %s, please update the config version", lastToken(field), version)
+	case FieldDeprecated:
+		return fmt.Sprintf("field %s was deprecated in version %s", lastToken(field), version)
+	default:
+		return ""
+	}
+}
+
+// defaultValueChangeMessage returns the message used for DefaultChanged
+// changes that don't specify a message explicitly. The first default of a
+// field is not described as a change.
+func defaultValueChangeMessage(field, value, version string, first bool) string {
+	if first {
+		return fmt.Sprintf("the default of field %s is %s in version %s", lastToken(field), value, version)
+	}
+	return fmt.Sprintf("the default of field %s changes to %s in version %s", lastToken(field), value, version)
+}
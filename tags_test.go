@@ -0,0 +1,142 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/matryer/is"
+)
+
+func TestChangelogFromTags(t *testing.T) {
+	type processor struct {
+		Type      string `yaml:"type" evolvi:"deprecated=2.2,replaced-by=plugin,msg=please use plugin, it's better"`
+		Plugin    string `yaml:"plugin" evolvi:"introduced=2.2"`
+		Condition string `yaml:"condition" evolvi:"introduced=2.1"`
+	}
+	type connector struct {
+		Processors []processor `yaml:"processors"`
+	}
+	type pipeline struct {
+		Connectors map[string]connector `yaml:"connectors"`
+		Processors []*processor         `yaml:"processors"`
+		Legacy     string               `yaml:"legacy" evolvi:"introduced=2.0.1,deprecated=2.1.0"`
+	}
+	type config struct {
+		Version   string     `yaml:"version"`
+		Pipelines []pipeline `yaml:"pipelines"`
+	}
+
+	is := is.New(t)
+
+	got, err := ChangelogFromTags(reflect.TypeFor[config](), "yaml", semver.MustParse("2.0"))
+	is.NoErr(err)
+
+	want := map[string][]Change{
+		"2.0.0": nil,
+		"2.0.1": {{
+			Field:      "pipelines.*.legacy",
+			ChangeType: FieldIntroduced,
+			Message:    "field legacy was introduced in version 2.0.1, please update the config version",
+		}},
+		"2.1.0": {{
+			Field:      "pipelines.*.connectors.*.processors.*.condition",
+			ChangeType: FieldIntroduced,
+			Message:    "field condition was introduced in version 2.1, please update the config version",
+		}, {
+			Field:      "pipelines.*.processors.*.condition",
+			ChangeType: FieldIntroduced,
+			Message:    "field condition was introduced in version 2.1, please update the config version",
+		}, {
+			Field:      "pipelines.*.legacy",
+			ChangeType: FieldDeprecated,
+			Message:    "field legacy was deprecated in version 2.1.0",
+		}},
+		"2.2.0": {{
+			Field:      "pipelines.*.connectors.*.processors.*.type",
+			ChangeType: FieldDeprecated,
+			Message:    "please use plugin, it's better",
+			ReplacedBy: "plugin",
+		}, {
+			Field:      "pipelines.*.connectors.*.processors.*.plugin",
+			ChangeType: FieldIntroduced,
+			Message:    "field plugin was introduced in version 2.2, please update the config version",
+		}, {
+			Field:      "pipelines.*.processors.*.type",
+			ChangeType: FieldDeprecated,
+			Message:    "please use plugin, it's better",
+			ReplacedBy: "plugin",
+		}, {
+			Field:      "pipelines.*.processors.*.plugin",
+			ChangeType: FieldIntroduced,
+			Message:    "field plugin was introduced in version 2.2, please update the config version",
+		}},
+	}
+
+	index := got.Index()
+	is.Equal(len(index.Versions()), len(want))
+	for _, v := range index.Versions() {
+		is.Equal(index.Changes(v), want[v.String()])
+	}
+}
+
+func TestChangelogFromTags_Invalid(t *testing.T) {
+	testCases := []struct {
+		name    string
+		typ     reflect.Type
+		wantErr string
+	}{{
+		name: "unknown key",
+		typ: reflect.TypeFor[struct {
+			Field string `yaml:"field" evolvi:"removed=1.0"`
+		}](),
+		wantErr: `field field: invalid evolvi tag "removed=1.0": unknown key "removed"`,
+	}, {
+		name: "invalid version",
+		typ: reflect.TypeFor[struct {
+			Field string `yaml:"field" evolvi:"introduced=one"`
+		}](),
+		wantErr: `field field: invalid evolvi tag "introduced=one": invalid semantic version`,
+	}, {
+		name: "missing value",
+		typ: reflect.TypeFor[struct {
+			Field string `yaml:"field" evolvi:"introduced"`
+		}](),
+		wantErr: `field field: invalid evolvi tag "introduced": expected key=value`,
+	}, {
+		name: "empty default",
+		typ: reflect.TypeFor[struct {
+			Field string `yaml:"field" evolvi:"default@1.0="`
+		}](),
+		wantErr: `field field: invalid evolvi tag "default@1.0=": empty default value`,
+	}, {
+		name: "invalid default version",
+		typ: reflect.TypeFor[struct {
+			Field string `yaml:"field" evolvi:"default@one=1"`
+		}](),
+		wantErr: `field field: invalid evolvi tag "default@one=1": invalid semantic version`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			_, err := ChangelogFromTags(tc.typ, "yaml")
+			is.True(err != nil)
+			is.Equal(err.Error(), tc.wantErr)
+		})
+	}
+}
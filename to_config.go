@@ -0,0 +1,94 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"context"
+	"slices"
+	"strings"
+)
+
+// ContextVersionedConfig can be implemented by versioned configs to convert
+// them to the config with a context and return warnings. Parser prefers it
+// over VersionedConfig.ToConfig. Use FieldWarning to create warnings about
+// fields, they contain the position of the field in the parsed document.
+type ContextVersionedConfig[T any] interface {
+	ToConfigContext(ctx context.Context) (T, Warnings, error)
+}
+
+// FieldPositions contains the positions of the fields in a document, keyed by
+// the dot separated path of the field (e.g. "pipelines.0.processors.1.type").
+type FieldPositions map[string]Position
+
+type fieldPositionsCtxKey struct{}
+
+type fieldPathCtxKey struct{}
+
+// ContextWithFieldPositions returns a context that carries positions. Parser
+// adds empty positions to the context passed to
+// VersionedConfigParser.ParseVersionedConfig, which can be populated by the
+// parser and are then used by FieldWarning in
+// ContextVersionedConfig.ToConfigContext.
+func ContextWithFieldPositions(ctx context.Context, positions FieldPositions) context.Context {
+	return context.WithValue(ctx, fieldPositionsCtxKey{}, positions)
+}
+
+// FieldPositionsFromContext returns the positions carried by the context, or
+// nil.
+func FieldPositionsFromContext(ctx context.Context) FieldPositions {
+	positions, _ := ctx.Value(fieldPositionsCtxKey{}).(FieldPositions)
+	return positions
+}
+
+// ContextWithFieldPath returns a context with the field path extended by
+// tokens. ToConfigContext implementations use it to pass the path of nested
+// structs to their conversion functions, e.g.:
+//
+//	ctx = evolviconf.ContextWithFieldPath(ctx, "processors", strconv.Itoa(i))
+func ContextWithFieldPath(ctx context.Context, tokens ...string) context.Context {
+	path := FieldPathFromContext(ctx)
+	return context.WithValue(ctx, fieldPathCtxKey{}, append(slices.Clip(path), tokens...))
+}
+
+// FieldPathFromContext returns the field path carried by the context.
+func FieldPathFromContext(ctx context.Context) []string {
+	path, _ := ctx.Value(fieldPathCtxKey{}).([]string)
+	return path
+}
+
+// FieldWarning returns a warning about field, relative to the field path in
+// the context. The position of the warning is looked up in the field
+// positions carried by the context.
+func FieldWarning(ctx context.Context, field, message string) Warning {
+	path := strings.Join(append(slices.Clip(FieldPathFromContext(ctx)), field), ".")
+	pos, ok := FieldPositionsFromContext(ctx)[path]
+	if !ok {
+		pos = Position{Field: field}
+	}
+	return Warning{
+		Position: pos,
+		Message:  message,
+	}
+}
+
+// toConfig converts the versioned config to the config, preferring
+// ContextVersionedConfig.
+func toConfig[T any](ctx context.Context, config VersionedConfig[T]) (T, Warnings, error) {
+	if c, ok := config.(ContextVersionedConfig[T]); ok {
+		return c.ToConfigContext(ctx)
+	}
+	out, err := config.ToConfig()
+	return out, nil, err
+}
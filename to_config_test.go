@@ -0,0 +1,134 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/matryer/is"
+)
+
+// contextTestConfig warns about an empty name when converted.
+type contextTestConfig struct {
+	testConfig
+}
+
+func (c contextTestConfig) ToConfigContext(ctx context.Context) (testConfig, Warnings, error) {
+	var warnings Warnings
+	if c.Name == "" {
+		warnings = append(warnings, FieldWarning(ContextWithFieldPath(ctx, "metadata"), "name", "name is empty"))
+	}
+	return c.testConfig, warnings, nil
+}
+
+// contextTestParser returns contextTestConfig and records a position for
+// the field metadata.name.
+type contextTestParser struct {
+	testParser
+}
+
+func (p contextTestParser) ParseVersionedConfig(ctx context.Context, dec *json.Decoder, version *semver.Version) (VersionedConfig[testConfig], Warnings, error) {
+	cfg, warnings, err := p.testParser.ParseVersionedConfig(ctx, dec, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	if positions := FieldPositionsFromContext(ctx); positions != nil {
+		positions["metadata.name"] = Position{Field: "name", Line: 3, Column: 5}
+	}
+	return contextTestConfig{cfg.(testConfig)}, append(warnings, Warning{Message: "parsed", Position: Position{Line: 4}}), nil
+}
+
+// duplicateTestConfig returns the same warning as duplicateTestParser.
+type duplicateTestConfig struct {
+	testConfig
+}
+
+func (c duplicateTestConfig) ToConfigContext(context.Context) (testConfig, Warnings, error) {
+	return c.testConfig, Warnings{
+		{Message: "converted", Position: Position{Line: 2, Column: 3}, Code: CodeFieldDeprecated},
+		{Message: "converted", Position: Position{Line: 2, Column: 3}, Code: CodeUnknownField},
+	}, nil
+}
+
+type duplicateTestParser struct {
+	testParser
+}
+
+func (p duplicateTestParser) ParseVersionedConfig(ctx context.Context, dec *json.Decoder, version *semver.Version) (VersionedConfig[testConfig], Warnings, error) {
+	cfg, _, err := p.testParser.ParseVersionedConfig(ctx, dec, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	return duplicateTestConfig{cfg.(testConfig)}, Warnings{
+		{Message: "parsed", Position: Position{Line: 2, Column: 3}, Code: CodeFieldDeprecated},
+	}, nil
+}
+
+func TestParser_ToConfigContext(t *testing.T) {
+	is := is.New(t)
+	parser := NewParser[testConfig, *json.Decoder](contextTestParser{newTestParser("^1", "1.1")})
+
+	got, warnings, err := parser.Parse(context.Background(), strings.NewReader(`{"version":"1.0"}{"version":"1.0","name":"a"}`))
+	is.NoErr(err)
+	is.Equal(got, []testConfig{
+		{Version: "1.0", ParsedVersion: "1.0.0"},
+		{Version: "1.0", Name: "a", ParsedVersion: "1.0.0"},
+	})
+	// warnings of the conversion contain the recorded positions and are
+	// sorted together with the parser warnings
+	is.Equal(warnings, Warnings{
+		{Message: "name is empty", Position: Position{Field: "name", Line: 3, Column: 5}},
+		{Message: "parsed", Position: Position{Line: 4}},
+		{Message: "parsed", Position: Position{Line: 4}},
+	})
+}
+
+func TestParser_ToConfigContext_Duplicates(t *testing.T) {
+	is := is.New(t)
+	parser := NewParser[testConfig, *json.Decoder](duplicateTestParser{newTestParser("^1", "1.1")})
+
+	_, warnings, err := parser.Parse(context.Background(), strings.NewReader(`{"version":"1.0"}`))
+	is.NoErr(err)
+	// the conversion warning with the same position and code is dropped
+	is.Equal(warnings, Warnings{
+		{Message: "parsed", Position: Position{Line: 2, Column: 3}, Code: CodeFieldDeprecated},
+		{Message: "converted", Position: Position{Line: 2, Column: 3}, Code: CodeUnknownField},
+	})
+}
+
+func TestFieldWarning(t *testing.T) {
+	is := is.New(t)
+
+	ctx := ContextWithFieldPath(context.Background(), "pipelines", "0")
+	is.Equal(FieldPathFromContext(ctx), []string{"pipelines", "0"})
+
+	// without positions only the field is known
+	is.Equal(FieldWarning(ctx, "type", "msg"), Warning{Position: Position{Field: "type"}, Message: "msg"})
+
+	ctx = ContextWithFieldPositions(ctx, FieldPositions{
+		"pipelines.0.type": {Field: "type", Line: 2, Column: 3, Value: "js"},
+	})
+	is.Equal(FieldWarning(ctx, "type", "msg"), Warning{Position: Position{Field: "type", Line: 2, Column: 3, Value: "js"}, Message: "msg"})
+
+	// sibling paths don't share the underlying array
+	a := ContextWithFieldPath(ctx, "processors", "0")
+	b := ContextWithFieldPath(ctx, "connectors", "1")
+	is.Equal(FieldPathFromContext(a), []string{"pipelines", "0", "processors", "0"})
+	is.Equal(FieldPathFromContext(b), []string{"pipelines", "0", "connectors", "1"})
+}
@@ -0,0 +1,163 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// TypePaths returns the paths of all fields in type t in the same notation
+// as Change.Field. Field names are taken from the struct tag with the supplied
+// key (e.g. "yaml" or "json"), elements of maps, slices and arrays that
+// contain structs are represented with a wildcard ("*"). Recursive types are
+// only expanded once per path.
+func TypePaths(t reflect.Type, tag string) []string {
+	var paths []string
+	walkTypeFields(t, tag, func(path []string, _ typeField) {
+		paths = append(paths, strings.Join(path, "."))
+	})
+	return paths
+}
+
+// ProposeChanges compares the versioned config types from and to and proposes
+// the changes that need to be added to the changelog for version. Fields that
+// only exist in type to are proposed as FieldIntroduced, fields that only
+// exist in type from are proposed as FieldDeprecated. If a parent field is
+// proposed, its children are skipped, as the change of the parent takes
+// precedence (see Changelog.Expand).
+func ProposeChanges(version *semver.Version, from, to reflect.Type, tag string) []Change {
+	fromPaths := TypePaths(from, tag)
+	toPaths := TypePaths(to, tag)
+
+	var changes []Change
+	for _, p := range diffPaths(toPaths, fromPaths) {
+		changes = append(changes, Change{
+			Field:      p,
+			ChangeType: FieldIntroduced,
+			Message:    defaultChangeMessage(p, FieldIntroduced, version.Original()),
+		})
+	}
+	for _, p := range diffPaths(fromPaths, toPaths) {
+		changes = append(changes, Change{
+			Field:      p,
+			ChangeType: FieldDeprecated,
+			Message:    fmt.Sprintf("field %s was removed in version %s", lastToken(p), version.Original()),
+		})
+	}
+	return changes
+}
+
+// MissingChanges returns the proposed changes that are not declared in
+// version of the changelog. A change is considered declared if the changelog
+// contains a change with the same field and change type in that version.
+func (cl Changelog) MissingChanges(version *semver.Version, proposed []Change) []Change {
+	var declared []Change
+	for v, changes := range cl {
+		if v.Equal(version) {
+			declared = append(declared, changes...)
+		}
+	}
+
+	var missing []Change
+	for _, p := range proposed {
+		if !slices.ContainsFunc(declared, func(c Change) bool {
+			return c.Field == p.Field && c.ChangeType == p.ChangeType
+		}) {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
+
+// CheckTypes proposes the changes between the versioned config types from
+// and to (see ProposeChanges) and returns an error for each change that is
+// missing in version of the changelog. It is meant to be called in tests to
+// make sure the changelog is updated together with the versioned config.
+func (cl Changelog) CheckTypes(version *semver.Version, from, to reflect.Type, tag string) error {
+	var errs []error
+	for _, c := range cl.MissingChanges(version, ProposeChanges(version, from, to, tag)) {
+		kind := "introduced"
+		if c.ChangeType == FieldDeprecated {
+			kind = "deprecated"
+		}
+		errs = append(errs, fmt.Errorf("%w: version %s: missing change for %s field %q", ErrInvalidChangelog, version, kind, c.Field))
+	}
+	return errors.Join(errs...)
+}
+
+// walkTypeFields calls fn for every field in type t, including nested fields.
+// The path contains the field names and wildcards for container elements.
+func walkTypeFields(t reflect.Type, tag string, fn func(path []string, f typeField)) {
+	var walk func(t reflect.Type, path []string, seen []reflect.Type)
+	walk = func(t reflect.Type, path []string, seen []reflect.Type) {
+		t = indirectType(t)
+		if elem, ok := containerElem(t); ok {
+			if !hasStructFields(elem) {
+				return
+			}
+			walk(elem, append(slices.Clip(path), "*"), seen)
+			return
+		}
+		if t.Kind() != reflect.Struct || slices.Contains(seen, t) {
+			return
+		}
+		seen = append(seen, t)
+		for _, f := range structFields(t, tag) {
+			fieldPath := append(slices.Clip(path), f.Name)
+			fn(fieldPath, f)
+			walk(f.Type, fieldPath, seen)
+		}
+	}
+	walk(t, nil, nil)
+}
+
+// hasStructFields reports whether t is a struct or a (nested) container of
+// structs.
+func hasStructFields(t reflect.Type) bool {
+	t = indirectType(t)
+	if elem, ok := containerElem(t); ok {
+		return hasStructFields(elem)
+	}
+	return t.Kind() == reflect.Struct
+}
+
+// diffPaths returns the paths in a that don't exist in b, skipping paths whose
+// parent path is already part of the result.
+func diffPaths(a, b []string) []string {
+	var diff []string
+	for _, p := range a {
+		if slices.Contains(b, p) {
+			continue
+		}
+		if slices.ContainsFunc(diff, func(parent string) bool {
+			return strings.HasPrefix(p, parent+".")
+		}) {
+			continue
+		}
+		diff = append(diff, p)
+	}
+	return diff
+}
+
+// lastToken returns the last token of a field path.
+func lastToken(path string) string {
+	return path[strings.LastIndex(path, ".")+1:]
+}
@@ -0,0 +1,155 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/matryer/is"
+)
+
+type testConfigV20 struct {
+	Version   string                `yaml:"version"`
+	Pipelines []testPipelineV20     `yaml:"pipelines"`
+	Labels    map[string]string     `yaml:"labels"`
+	Children  map[string]*testChild `yaml:"children"`
+}
+
+type testPipelineV20 struct {
+	ID         string                      `yaml:"id"`
+	Processors []testProcessorV20          `yaml:"processors"`
+	Connectors map[string]testConnectorV20 `yaml:"connectors"`
+	Legacy     struct{ Name string }       `yaml:"legacy"`
+	Ignored    string                      `yaml:"-"`
+}
+
+type testConnectorV20 struct {
+	Processors []testProcessorV20 `yaml:"processors"`
+}
+
+type testProcessorV20 struct {
+	Type string `yaml:"type"`
+}
+
+type testConfigV21 struct {
+	Version   string                `yaml:"version"`
+	Pipelines []testPipelineV21     `yaml:"pipelines"`
+	Labels    map[string]string     `yaml:"labels"`
+	Children  map[string]*testChild `yaml:"children"`
+}
+
+type testPipelineV21 struct {
+	ID         string                      `yaml:"id"`
+	Processors []testProcessorV21          `yaml:"processors"`
+	Connectors map[string]testConnectorV21 `yaml:"connectors"`
+	DLQ        struct {
+		Plugin string `yaml:"plugin"`
+	} `yaml:"dead-letter-queue"`
+}
+
+type testConnectorV21 struct {
+	Processors []testProcessorV21 `yaml:"processors"`
+}
+
+type testProcessorV21 struct {
+	Type      string `yaml:"type"`
+	Condition string `yaml:"condition"`
+}
+
+// testChild is a recursive type.
+type testChild struct {
+	Name     string       `yaml:"name"`
+	Children []*testChild `yaml:"children"`
+}
+
+func TestTypePaths(t *testing.T) {
+	is := is.New(t)
+
+	got := TypePaths(reflect.TypeFor[testConfigV20](), "yaml")
+	is.Equal(got, []string{
+		"version",
+		"pipelines",
+		"pipelines.*.id",
+		"pipelines.*.processors",
+		"pipelines.*.processors.*.type",
+		"pipelines.*.connectors",
+		"pipelines.*.connectors.*.processors",
+		"pipelines.*.connectors.*.processors.*.type",
+		"pipelines.*.legacy",
+		"pipelines.*.legacy.name",
+		"labels",
+		"children",
+		"children.*.name",
+		"children.*.children",
+	})
+}
+
+func TestProposeChanges(t *testing.T) {
+	is := is.New(t)
+
+	v21 := semver.MustParse("2.1")
+	got := ProposeChanges(v21, reflect.TypeFor[testConfigV20](), reflect.TypeFor[testConfigV21](), "yaml")
+	is.Equal(got, []Change{{
+		Field:      "pipelines.*.processors.*.condition",
+		ChangeType: FieldIntroduced,
+		Message:    "field condition was introduced in version 2.1, please update the config version",
+	}, {
+		Field:      "pipelines.*.connectors.*.processors.*.condition",
+		ChangeType: FieldIntroduced,
+		Message:    "field condition was introduced in version 2.1, please update the config version",
+	}, {
+		// children of an introduced field are not proposed
+		Field:      "pipelines.*.dead-letter-queue",
+		ChangeType: FieldIntroduced,
+		Message:    "field dead-letter-queue was introduced in version 2.1, please update the config version",
+	}, {
+		Field:      "pipelines.*.legacy",
+		ChangeType: FieldDeprecated,
+		Message:    "field legacy was removed in version 2.1",
+	}})
+}
+
+func TestChangelog_CheckTypes(t *testing.T) {
+	is := is.New(t)
+
+	from := reflect.TypeFor[testConfigV20]()
+	to := reflect.TypeFor[testConfigV21]()
+
+	complete := Changelog{
+		semver.MustParse("2.0"): {},
+		semver.MustParse("2.1"): {
+			{Field: "pipelines.*.processors.*.condition", ChangeType: FieldIntroduced},
+			{Field: "pipelines.*.connectors.*.processors.*.condition", ChangeType: FieldIntroduced},
+			{Field: "pipelines.*.dead-letter-queue", ChangeType: FieldIntroduced},
+			{Field: "pipelines.*.legacy", ChangeType: FieldDeprecated},
+		},
+	}
+	is.NoErr(complete.CheckTypes(semver.MustParse("2.1.0"), from, to, "yaml"))
+
+	incomplete := Changelog{
+		semver.MustParse("2.0"): {},
+		semver.MustParse("2.1"): {
+			{Field: "pipelines.*.processors.*.condition", ChangeType: FieldIntroduced},
+			{Field: "pipelines.*.dead-letter-queue", ChangeType: FieldIntroduced},
+		},
+	}
+	err := incomplete.CheckTypes(semver.MustParse("2.1"), from, to, "yaml")
+	is.True(errors.Is(err, ErrInvalidChangelog))
+	is.Equal(err.Error(), `invalid changelog: version 2.1.0: missing change for introduced field "pipelines.*.connectors.*.processors.*.condition"
+invalid changelog: version 2.1.0: missing change for deprecated field "pipelines.*.legacy"`)
+}
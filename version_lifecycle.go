@@ -0,0 +1,136 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"fmt"
+	"time"
+)
+
+// VersionStatus is the support status of a range of versions.
+type VersionStatus int
+
+const (
+	// VersionDeprecated versions can still be parsed, but produce a warning.
+	// If VersionLifecycle.Sunset is set, they are handled according to the
+	// SunsetPolicy from the sunset on.
+	VersionDeprecated VersionStatus = iota + 1
+	// VersionUnsupported versions can't be parsed anymore.
+	VersionUnsupported
+)
+
+func (s VersionStatus) String() string {
+	switch s {
+	case VersionDeprecated:
+		return "deprecated"
+	case VersionUnsupported:
+		return "unsupported"
+	default:
+		return fmt.Sprintf("VersionStatus(%d)", int(s))
+	}
+}
+
+// SunsetPolicy defines how deprecated versions are handled from their sunset
+// on.
+type SunsetPolicy int
+
+const (
+	// SunsetReject returns an error wrapping ErrUnsupportedVersion (default).
+	SunsetReject SunsetPolicy = iota
+	// SunsetWarn parses the document and returns a warning with severity
+	// SeverityError.
+	SunsetWarn
+)
+
+func (p SunsetPolicy) String() string {
+	switch p {
+	case SunsetReject:
+		return "reject"
+	case SunsetWarn:
+		return "warn"
+	default:
+		return fmt.Sprintf("SunsetPolicy(%d)", int(p))
+	}
+}
+
+// VersionLifecycle marks the versions matching Constraint as deprecated or
+// unsupported. Use a constraint like "<2" to define the minimum supported
+// version.
+type VersionLifecycle struct {
+	Constraint VersionConstraint
+	Status     VersionStatus
+	// Name is used to refer to the versions in messages (e.g. "v1"). Defaults
+	// to "version " followed by the version of the document.
+	Name string
+	// Sunset is the instant at which deprecated versions stop working,
+	// usually midnight at the start of a date (e.g. 2027-01-01 00:00 UTC
+	// means the versions stop working on 2027-01-01). The zero value means
+	// the versions are deprecated without a sunset date.
+	Sunset time.Time
+	// Message is appended to warnings and errors, it can be used to tell
+	// users how to migrate (e.g. "run 'conduit config upgrade'").
+	Message string
+}
+
+// name returns the name of the versions in messages.
+func (l VersionLifecycle) name(version string) string {
+	if l.Name != "" {
+		return l.Name
+	}
+	return "version " + version
+}
+
+// withMessage appends the lifecycle message to msg.
+func (l VersionLifecycle) withMessage(msg string) string {
+	if l.Message == "" {
+		return msg
+	}
+	return msg + ": " + l.Message
+}
+
+// check returns the warnings for the formatted version at time now, or an
+// error if the version is not supported anymore.
+func (l VersionLifecycle) check(version string, now time.Time, policy SunsetPolicy) (Warnings, error) {
+	name := l.name(version)
+	switch l.Status {
+	case VersionUnsupported:
+		return nil, fmt.Errorf("%w %s: %s", ErrUnsupportedVersion, version, l.withMessage(name+" is not supported anymore"))
+	case VersionDeprecated:
+		if l.Sunset.IsZero() {
+			return Warnings{{
+				Code:    CodeVersionDeprecated,
+				Message: l.withMessage(name + " is deprecated"),
+			}}, nil
+		}
+		sunset := l.Sunset.Format(time.DateOnly)
+		if now.Before(l.Sunset) {
+			return Warnings{{
+				Code:    CodeVersionDeprecated,
+				Message: l.withMessage(fmt.Sprintf("%s is deprecated and will stop working on %s", name, sunset)),
+			}}, nil
+		}
+		msg := l.withMessage(fmt.Sprintf("%s is deprecated and stopped working on %s", name, sunset))
+		if policy == SunsetWarn {
+			return Warnings{{
+				Code:     CodeVersionDeprecated,
+				Severity: SeverityError,
+				Message:  msg,
+			}}, nil
+		}
+		return nil, fmt.Errorf("%w %s: %s", ErrUnsupportedVersion, version, msg)
+	default:
+		return nil, fmt.Errorf("unknown version status %s", l.Status)
+	}
+}
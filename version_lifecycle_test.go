@@ -0,0 +1,157 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/matryer/is"
+)
+
+func TestParser_WithVersionLifecycles(t *testing.T) {
+	sunset := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := func() time.Time { return sunset.Add(-time.Nanosecond) }
+	at := func() time.Time { return sunset }
+	after := func() time.Time { return sunset.Add(time.Hour) }
+
+	lifecycles := []VersionLifecycle{{
+		Constraint: mustConstraint("<1"),
+		Status:     VersionUnsupported,
+		Message:    "upgrade to v2",
+	}, {
+		Constraint: mustConstraint("^1"),
+		Status:     VersionDeprecated,
+		Name:       "v1",
+		Sunset:     sunset,
+	}, {
+		Constraint: mustConstraint("~2.0"),
+		Status:     VersionDeprecated,
+	}}
+
+	testCases := []struct {
+		name         string
+		now          func() time.Time
+		policy       SunsetPolicy
+		src          string
+		wantWarning  string
+		wantSeverity Severity
+		wantErr      string
+	}{{
+		name:        "deprecated before sunset",
+		now:         before,
+		src:         `{"version":"1.1"}`,
+		wantWarning: "v1 is deprecated and will stop working on 2027-01-01",
+	}, {
+		name:    "deprecated at sunset",
+		now:     at,
+		src:     `{"version":"1.1"}`,
+		wantErr: "unsupported version 1.1: v1 is deprecated and stopped working on 2027-01-01",
+	}, {
+		name:    "deprecated after sunset",
+		now:     after,
+		src:     `{"version":"1.1"}`,
+		wantErr: "unsupported version 1.1: v1 is deprecated and stopped working on 2027-01-01",
+	}, {
+		name:         "deprecated after sunset with warn policy",
+		now:          after,
+		policy:       SunsetWarn,
+		src:          `{"version":"1.1"}`,
+		wantWarning:  "v1 is deprecated and stopped working on 2027-01-01",
+		wantSeverity: SeverityError,
+	}, {
+		name:        "deprecated without sunset",
+		now:         after,
+		src:         `{"version":"2.0"}`,
+		wantWarning: "version 2.0 is deprecated",
+	}, {
+		name:    "unsupported",
+		now:     before,
+		src:     `{"version":"0.9"}`,
+		wantErr: "unsupported version 0.9: version 0.9 is not supported anymore: upgrade to v2",
+	}, {
+		name: "supported",
+		now:  after,
+		src:  `{"version":"2.1"}`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			parser := NewParser[testConfig, *json.Decoder](
+				newTestParser("<1", "0.9"),
+				newTestParser("^1", "1.1"),
+				newTestParser("^2", "2.1"),
+			).
+				WithVersionLifecycles(lifecycles...).
+				WithVersionPolicy(VersionPolicy{Sunset: tc.policy}).
+				WithClock(tc.now)
+
+			_, warnings, err := parser.Parse(context.Background(), strings.NewReader(tc.src))
+			if tc.wantErr != "" {
+				is.True(errors.Is(err, ErrUnsupportedVersion))
+				is.Equal(err.Error(), tc.wantErr)
+				return
+			}
+			is.NoErr(err)
+			if tc.wantWarning == "" {
+				is.Equal(len(warnings), 0)
+				return
+			}
+			is.Equal(warnings, Warnings{{
+				Message:  tc.wantWarning,
+				Severity: tc.wantSeverity,
+				Code:     CodeVersionDeprecated,
+			}})
+		})
+	}
+}
+
+func TestMux_WithVersionLifecycles(t *testing.T) {
+	is := is.New(t)
+	mux := NewMux[*json.Decoder](testKindParser{}, testKindParser{}).
+		WithVersionLifecycles("pipeline", VersionLifecycle{
+			Constraint: mustConstraint("^1"),
+			Status:     VersionDeprecated,
+			Name:       "v1",
+			Sunset:     time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+		}).
+		WithClock(func() time.Time { return time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC) })
+	// lifecycles are applied to kinds registered later
+	RegisterKind[testConfig](mux, "pipeline", newTestParser("^1", "1.1"))
+	RegisterKind[testConfig](mux, "other", newTestParser("^1", "1.1"))
+
+	_, warnings, err := mux.Parse(context.Background(), strings.NewReader(
+		`{"kind":"pipeline","version":"1.0"}{"kind":"other","version":"1.0"}`,
+	))
+	is.NoErr(err)
+	is.Equal(warnings, Warnings{{
+		Message: "v1 is deprecated and will stop working on 2027-01-01",
+		Code:    CodeVersionDeprecated,
+	}})
+}
+
+func mustConstraint(c string) *semver.Constraints {
+	constraint, err := semver.NewConstraint(c)
+	if err != nil {
+		panic(err)
+	}
+	return constraint
+}
@@ -0,0 +1,205 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"fmt"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// VersionPolicy defines how a parser handles documents without a version and
+// documents with a version that is newer than the latest known version.
+type VersionPolicy struct {
+	Missing MissingVersionPolicy
+	// Pinned is the version used for documents without a version if Missing
+	// is MissingVersionPinned.
+	Pinned *semver.Version
+	Newer  NewerVersionPolicy
+	// Sunset defines how deprecated versions are handled from their sunset
+	// on, see VersionLifecycle.
+	Sunset SunsetPolicy
+	// Prerelease defines how documents with a pre-release version are
+	// handled. Production builds can use it to warn about or reject
+	// pre-release configs.
+	Prerelease PrereleasePolicy
+}
+
+// MissingVersionPolicy defines which version is used for documents that
+// don't specify a version and whose version can't be resolved by a version
+// resolver.
+type MissingVersionPolicy int
+
+const (
+	// MissingVersionLatest uses the latest known version (default).
+	MissingVersionLatest MissingVersionPolicy = iota
+	// MissingVersionOldest uses the oldest known version, see
+	// OldestKnownVersioner.
+	MissingVersionOldest
+	// MissingVersionPinned uses VersionPolicy.Pinned.
+	MissingVersionPinned
+	// MissingVersionReject returns an error wrapping ErrVersionNotSpecified.
+	MissingVersionReject
+)
+
+func (p MissingVersionPolicy) String() string {
+	switch p {
+	case MissingVersionLatest:
+		return "latest"
+	case MissingVersionOldest:
+		return "oldest"
+	case MissingVersionPinned:
+		return "pinned"
+	case MissingVersionReject:
+		return "reject"
+	default:
+		return fmt.Sprintf("MissingVersionPolicy(%d)", int(p))
+	}
+}
+
+// NewerVersionPolicy defines how documents with a version that satisfies the
+// constraint of a parser, but is newer than its latest known version, are
+// handled.
+type NewerVersionPolicy int
+
+const (
+	// NewerVersionWarn parses the document with the best matching parser and
+	// returns a warning (default).
+	NewerVersionWarn NewerVersionPolicy = iota
+	// NewerVersionRejectMinor returns an error if the major or minor version
+	// is newer than the latest known version, newer patch versions are
+	// handled like with NewerVersionWarn. Versions of schemes other than
+	// SemverScheme have no patch versions, all newer versions are rejected.
+	NewerVersionRejectMinor
+	// NewerVersionReject returns an error for all versions newer than the
+	// latest known version.
+	NewerVersionReject
+)
+
+func (p NewerVersionPolicy) String() string {
+	switch p {
+	case NewerVersionWarn:
+		return "warn"
+	case NewerVersionRejectMinor:
+		return "reject-minor"
+	case NewerVersionReject:
+		return "reject"
+	default:
+		return fmt.Sprintf("NewerVersionPolicy(%d)", int(p))
+	}
+}
+
+// PrereleasePolicy defines how documents with a pre-release version (e.g.
+// 2.3.0-beta.1) are handled.
+type PrereleasePolicy int
+
+const (
+	// PrereleaseAllow parses pre-release versions like any other version
+	// (default).
+	PrereleaseAllow PrereleasePolicy = iota
+	// PrereleaseWarn parses pre-release versions and returns a warning.
+	PrereleaseWarn
+	// PrereleaseReject returns an error wrapping ErrUnsupportedVersion for
+	// pre-release versions.
+	PrereleaseReject
+)
+
+func (p PrereleasePolicy) String() string {
+	switch p {
+	case PrereleaseAllow:
+		return "allow"
+	case PrereleaseWarn:
+		return "warn"
+	case PrereleaseReject:
+		return "reject"
+	default:
+		return fmt.Sprintf("PrereleasePolicy(%d)", int(p))
+	}
+}
+
+// OldestKnownVersioner can be implemented by a VersionedConfigParser to
+// report the oldest version it supports, which is used by
+// MissingVersionOldest. Parsers that don't implement it are assumed to only
+// support their latest known version.
+type OldestKnownVersioner interface {
+	OldestKnownVersion() *semver.Version
+}
+
+// PrereleaseAcceptor can be implemented by a VersionedConfigParser to opt in
+// to parsing pre-release versions. Constraints exclude pre-release versions
+// unless the constraint itself contains a pre-release, a parser that accepts
+// pre-releases is also used for pre-release versions whose release version
+// satisfies its constraint (e.g. 2.3.0-beta.1 for constraint ^2).
+type PrereleaseAcceptor interface {
+	AcceptsPrerelease() bool
+}
+
+// CheckConstraint returns true if the version satisfies the constraint of the
+// parser, taking into account if the parser accepts pre-release versions (see
+// PrereleaseAcceptor). Parser uses it to find the parser for a version, tools
+// that look up parsers themselves should use it to agree with Parser.
+func CheckConstraint(parser any, constraint VersionConstraint, version *semver.Version) bool {
+	if constraint.Check(version) {
+		return true
+	}
+	if version.Prerelease() == "" {
+		return false
+	}
+	if a, ok := parser.(PrereleaseAcceptor); !ok || !a.AcceptsPrerelease() {
+		return false
+	}
+	return constraint.Check(semver.New(version.Major(), version.Minor(), version.Patch(), "", ""))
+}
+
+// checkPrerelease returns the warnings for a pre-release version, or an
+// error if the policy rejects pre-release versions.
+func (vp VersionPolicy) checkPrerelease(version *semver.Version, scheme VersionScheme) (Warnings, error) {
+	if version.Prerelease() == "" {
+		return nil, nil
+	}
+	switch vp.Prerelease {
+	case PrereleaseAllow:
+		return nil, nil
+	case PrereleaseWarn:
+		return Warnings{{
+			Code:    CodeVersionPrerelease,
+			Message: fmt.Sprintf("config uses pre-release version %s, which may change without notice", scheme.FormatVersion(version)),
+		}}, nil
+	case PrereleaseReject:
+		return nil, fmt.Errorf("%w %s (pre-release version policy: %s)", ErrUnsupportedVersion, scheme.FormatVersion(version), vp.Prerelease)
+	default:
+		return nil, fmt.Errorf("unknown pre-release version policy %s", vp.Prerelease)
+	}
+}
+
+// rejectsNewer returns true if the policy rejects version, which is newer
+// than latest. Versions are compared with scheme.
+func (vp VersionPolicy) rejectsNewer(version, latest *semver.Version, scheme VersionScheme) bool {
+	switch vp.Newer {
+	case NewerVersionReject:
+		return scheme.Compare(version, latest) > 0
+	case NewerVersionRejectMinor:
+		if scheme != SemverScheme {
+			// only semantic versions have patch versions
+			return scheme.Compare(version, latest) > 0
+		}
+		return scheme.Compare(
+			semver.New(version.Major(), version.Minor(), 0, "", ""),
+			semver.New(latest.Major(), latest.Minor(), 0, "", ""),
+		) > 0
+	default:
+		return false
+	}
+}
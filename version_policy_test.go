@@ -0,0 +1,230 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/matryer/is"
+)
+
+func TestParser_WithVersionPolicy(t *testing.T) {
+	v1 := newTestParser("^1", "1.1")
+	v1.oldest = semver.MustParse("1.0")
+	v2 := newTestParser("^2", "2.0")
+
+	testCases := []struct {
+		name        string
+		policy      VersionPolicy
+		src         string
+		wantVersion string
+		wantWarning string
+		wantErr     error
+	}{{
+		name:        "missing: latest",
+		policy:      VersionPolicy{},
+		src:         `{"name":"a"}`,
+		wantVersion: "2.0.0",
+		wantWarning: "no version defined, falling back to parser version 2.0",
+	}, {
+		name:        "missing: oldest",
+		policy:      VersionPolicy{Missing: MissingVersionOldest},
+		src:         `{"name":"a"}`,
+		wantVersion: "1.0.0",
+		wantWarning: "no version defined, using oldest known version 1.0 (missing version policy: oldest)",
+	}, {
+		name:        "missing: pinned",
+		policy:      VersionPolicy{Missing: MissingVersionPinned, Pinned: semver.MustParse("1.1")},
+		src:         `{"name":"a"}`,
+		wantVersion: "1.1.0",
+		wantWarning: "no version defined, using pinned version 1.1 (missing version policy: pinned)",
+	}, {
+		name:    "missing: pinned without version",
+		policy:  VersionPolicy{Missing: MissingVersionPinned},
+		src:     `{"name":"a"}`,
+		wantErr: errors.New("missing version policy pinned requires a pinned version"),
+	}, {
+		name:    "missing: reject",
+		policy:  VersionPolicy{Missing: MissingVersionReject},
+		src:     `{"name":"a"}`,
+		wantErr: ErrVersionNotSpecified,
+	}, {
+		name:        "newer: warn",
+		policy:      VersionPolicy{},
+		src:         `{"version":"1.2"}`,
+		wantVersion: "1.2.0",
+		wantWarning: "no parser found for version 1.2, using parser for version 1.1 with costraints ^1",
+	}, {
+		name:    "newer: reject minor",
+		policy:  VersionPolicy{Newer: NewerVersionRejectMinor},
+		src:     `{"version":"1.2"}`,
+		wantErr: ErrUnsupportedVersion,
+	}, {
+		name:        "newer: reject minor allows patch",
+		policy:      VersionPolicy{Newer: NewerVersionRejectMinor},
+		src:         `{"version":"1.1.1"}`,
+		wantVersion: "1.1.1",
+		wantWarning: "no parser found for version 1.1.1, using parser for version 1.1 with costraints ^1 (newer version policy: reject-minor)",
+	}, {
+		name:    "newer: reject",
+		policy:  VersionPolicy{Newer: NewerVersionReject},
+		src:     `{"version":"1.1.1"}`,
+		wantErr: ErrUnsupportedVersion,
+	}, {
+		name:        "known version is not affected",
+		policy:      VersionPolicy{Missing: MissingVersionReject, Newer: NewerVersionReject},
+		src:         `{"version":"1.0"}`,
+		wantVersion: "1.0.0",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			parser := NewParser[testConfig, *json.Decoder](v1, v2).WithVersionPolicy(tc.policy)
+
+			got, warnings, err := parser.Parse(context.Background(), strings.NewReader(tc.src))
+			if tc.wantErr != nil {
+				is.True(err != nil)
+				is.True(errors.Is(err, tc.wantErr) || err.Error() == tc.wantErr.Error())
+				return
+			}
+			is.NoErr(err)
+			is.Equal(got[0].ParsedVersion, tc.wantVersion)
+			if tc.wantWarning == "" {
+				is.Equal(len(warnings), 0)
+				return
+			}
+			is.Equal(len(warnings), 1)
+			is.Equal(warnings[0].Message, tc.wantWarning)
+		})
+	}
+}
+
+func TestMux_WithVersionPolicy(t *testing.T) {
+	is := is.New(t)
+	mux := newTestMux().WithVersionPolicy(VersionPolicy{Missing: MissingVersionReject})
+
+	_, _, err := mux.Parse(context.Background(), strings.NewReader(`{"kind":"connector"}`))
+	is.True(errors.Is(err, ErrVersionNotSpecified))
+
+	_, _, err = mux.Parse(context.Background(), strings.NewReader(`{"kind":"pipeline","version":"3.0"}`))
+	is.True(errors.Is(err, ErrUnsupportedVersion))
+}
+
+func TestParser_Prerelease(t *testing.T) {
+	v1 := newTestParser("^1", "1.1")
+	v2 := newTestParser("^2", "2.3.0-beta.1")
+	v2.prerelease = true
+
+	testCases := []struct {
+		name        string
+		policy      PrereleasePolicy
+		src         string
+		wantVersion string
+		wantWarning string
+		wantErr     string
+	}{{
+		name:        "opted in parser",
+		src:         `{"version":"2.3.0-beta.1"}`,
+		wantVersion: "2.3.0-beta.1",
+	}, {
+		name:        "older pre-release",
+		src:         `{"version":"2.0.0-rc.1"}`,
+		wantVersion: "2.0.0-rc.1",
+	}, {
+		name:        "newer pre-release",
+		src:         `{"version":"2.3.0-beta.2"}`,
+		wantVersion: "2.3.0-beta.2",
+		wantWarning: "no parser found for version 2.3.0-beta.2, using parser for version 2.3.0-beta.1 with costraints ^2",
+	}, {
+		name:    "parser did not opt in",
+		src:     `{"version":"1.1.0-beta.1"}`,
+		wantErr: "unsupported version 1.1.0-beta.1",
+	}, {
+		name:        "build metadata",
+		src:         `{"version":"1.1.0+build.5"}`,
+		wantVersion: "1.1.0+build.5",
+	}, {
+		name:        "warn policy",
+		policy:      PrereleaseWarn,
+		src:         `{"version":"2.3.0-beta.1"}`,
+		wantVersion: "2.3.0-beta.1",
+		wantWarning: "config uses pre-release version 2.3.0-beta.1, which may change without notice",
+	}, {
+		name:        "warn policy with release version",
+		policy:      PrereleaseWarn,
+		src:         `{"version":"1.0"}`,
+		wantVersion: "1.0.0",
+	}, {
+		name:    "reject policy",
+		policy:  PrereleaseReject,
+		src:     `{"version":"2.3.0-beta.1"}`,
+		wantErr: "unsupported version 2.3.0-beta.1 (pre-release version policy: reject)",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			parser := NewParser[testConfig, *json.Decoder](v1, v2).
+				WithVersionPolicy(VersionPolicy{Prerelease: tc.policy})
+
+			got, warnings, err := parser.Parse(context.Background(), strings.NewReader(tc.src))
+			if tc.wantErr != "" {
+				is.True(errors.Is(err, ErrUnsupportedVersion))
+				is.Equal(err.Error(), tc.wantErr)
+				return
+			}
+			is.NoErr(err)
+			is.Equal(got[0].ParsedVersion, tc.wantVersion)
+			if tc.wantWarning == "" {
+				is.Equal(len(warnings), 0)
+				return
+			}
+			is.Equal(len(warnings), 1)
+			is.Equal(warnings[0].Message, tc.wantWarning)
+		})
+	}
+}
+
+func TestCheckConstraint(t *testing.T) {
+	optedIn := newTestParser("^2", "2.3")
+	optedIn.prerelease = true
+	optedOut := newTestParser("^2", "2.3")
+
+	testCases := []struct {
+		name    string
+		parser  testParser
+		version string
+		want    bool
+	}{
+		{name: "release version", parser: optedOut, version: "2.1.0", want: true},
+		{name: "version outside of constraint", parser: optedIn, version: "3.0.0", want: false},
+		{name: "pre-release, parser opted in", parser: optedIn, version: "2.3.0-beta.1", want: true},
+		{name: "pre-release, parser did not opt in", parser: optedOut, version: "2.3.0-beta.1", want: false},
+		{name: "pre-release outside of constraint", parser: optedIn, version: "3.0.0-beta.1", want: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			got := CheckConstraint(tc.parser, tc.parser.Constraint(), semver.MustParse(tc.version))
+			is.Equal(got, tc.want)
+		})
+	}
+}
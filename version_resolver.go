@@ -0,0 +1,176 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// maxSourceHeaderSize is the maximum number of bytes read from the start of
+// the source and passed to version resolvers in Source.Header.
+const maxSourceHeaderSize = 4096
+
+// Source describes the input of Parser.Parse.
+type Source struct {
+	// Name is the name of the source (e.g. the file name), set with
+	// ContextWithSourceName. Empty if unknown.
+	Name string
+	// Header contains the first bytes of the source (up to 4KiB).
+	Header []byte
+}
+
+// VersionResolver resolves the version of documents that don't specify a
+// version. It returns nil if it can't resolve the version.
+type VersionResolver interface {
+	ResolveVersion(ctx context.Context, src Source) (*semver.Version, error)
+}
+
+// VersionResolverFunc is an adapter that allows using a function as a
+// VersionResolver.
+type VersionResolverFunc func(ctx context.Context, src Source) (*semver.Version, error)
+
+func (f VersionResolverFunc) ResolveVersion(ctx context.Context, src Source) (*semver.Version, error) {
+	return f(ctx, src)
+}
+
+// CommentVersionResolver returns a resolver that reads the version from the
+// leading comment lines of the source, e.g. "# config-version: 2.1" if key is
+// "config-version". Comments starting with "#" and "//" are supported. The
+// version is parsed with the version scheme of the parser (see
+// VersionSchemeSetter), SemverScheme by default.
+func CommentVersionResolver(key string) VersionResolver {
+	return &commentVersionResolver{key: key, scheme: SemverScheme}
+}
+
+type commentVersionResolver struct {
+	key    string
+	scheme VersionScheme
+}
+
+func (r *commentVersionResolver) SetVersionScheme(scheme VersionScheme) {
+	r.scheme = scheme
+}
+
+func (r *commentVersionResolver) ResolveVersion(_ context.Context, src Source) (*semver.Version, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(src.Header))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		comment, ok := strings.CutPrefix(line, "#")
+		if !ok {
+			comment, ok = strings.CutPrefix(line, "//")
+		}
+		if !ok {
+			// end of leading comments
+			return nil, nil
+		}
+		k, v, ok := strings.Cut(comment, ":")
+		if !ok || strings.TrimSpace(k) != r.key {
+			continue
+		}
+		version, err := r.scheme.ParseVersion(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("invalid version in comment %q: %w", line, err)
+		}
+		return version, nil
+	}
+	return nil, nil
+}
+
+// FileNameVersionResolver returns a resolver that extracts the version from
+// the base name of the source with pattern. The first submatch of the pattern
+// is parsed as the version, e.g. `\.v(\d+(?:\.\d+)*)\.ya?ml$` resolves the
+// version 2.1 from the name "pipelines.v2.1.yml". The version is parsed with
+// the version scheme of the parser (see VersionSchemeSetter), SemverScheme by
+// default.
+func FileNameVersionResolver(pattern *regexp.Regexp) VersionResolver {
+	return &fileNameVersionResolver{pattern: pattern, scheme: SemverScheme}
+}
+
+type fileNameVersionResolver struct {
+	pattern *regexp.Regexp
+	scheme  VersionScheme
+}
+
+func (r *fileNameVersionResolver) SetVersionScheme(scheme VersionScheme) {
+	r.scheme = scheme
+}
+
+func (r *fileNameVersionResolver) ResolveVersion(_ context.Context, src Source) (*semver.Version, error) {
+	if src.Name == "" {
+		return nil, nil
+	}
+	m := r.pattern.FindStringSubmatch(filepath.Base(src.Name))
+	if len(m) < 2 {
+		return nil, nil
+	}
+	version, err := r.scheme.ParseVersion(m[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid version in file name %q: %w", src.Name, err)
+	}
+	return version, nil
+}
+
+// SourceDefaultVersionResolver returns a resolver that resolves the version
+// based on the name of the source. The key "" applies to sources without a
+// name.
+func SourceDefaultVersionResolver(defaults map[string]*semver.Version) VersionResolver {
+	return VersionResolverFunc(func(_ context.Context, src Source) (*semver.Version, error) {
+		return defaults[src.Name], nil
+	})
+}
+
+// ContextVersionResolver returns a resolver that resolves the version set
+// with ContextWithDefaultVersion.
+func ContextVersionResolver() VersionResolver {
+	return VersionResolverFunc(func(ctx context.Context, _ Source) (*semver.Version, error) {
+		v, _ := ctx.Value(defaultVersionCtxKey{}).(*semver.Version)
+		return v, nil
+	})
+}
+
+type (
+	sourceNameCtxKey     struct{}
+	defaultVersionCtxKey struct{}
+)
+
+// ContextWithSourceName returns a context that carries the name of the source
+// passed to Parser.Parse, which is used by version resolvers.
+func ContextWithSourceName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, sourceNameCtxKey{}, name)
+}
+
+// SourceNameFromContext returns the source name set with
+// ContextWithSourceName or an empty string.
+func SourceNameFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(sourceNameCtxKey{}).(string)
+	return name
+}
+
+// ContextWithDefaultVersion returns a context that carries the default
+// version of the source passed to Parser.Parse, see ContextVersionResolver.
+func ContextWithDefaultVersion(ctx context.Context, version *semver.Version) context.Context {
+	return context.WithValue(ctx, defaultVersionCtxKey{}, version)
+}
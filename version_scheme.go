@@ -0,0 +1,256 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// VersionScheme defines how versions are written in config files, how they
+// are compared and how constraints are checked. Versions of all schemes are
+// represented as semantic versions (e.g. the date 2024-06-01 as 2024.6.1), so
+// that they can be used as keys in a Changelog. The scheme owns the semantics
+// of its versions: the Parser compares versions with Compare, checks them
+// against constraints parsed with ParseConstraint and formats them with
+// FormatVersion. Configure the scheme once with Parser.WithVersionScheme, it
+// is passed on to parsers implementing VersionSchemeSetter.
+type VersionScheme interface {
+	// Name returns the name of the scheme (e.g. "semver").
+	Name() string
+	// ParseVersion parses a version written in the scheme.
+	ParseVersion(s string) (*semver.Version, error)
+	// FormatVersion formats a version returned by ParseVersion in the
+	// scheme.
+	FormatVersion(v *semver.Version) string
+	// Compare returns -1, 0 or +1 depending on whether version a is less
+	// than, equal to or greater than version b.
+	Compare(a, b *semver.Version) int
+	// ParseConstraint parses a constraint, versions in the constraint are
+	// written in the scheme (e.g. ">= 2024-06-01").
+	ParseConstraint(s string) (VersionConstraint, error)
+}
+
+// VersionConstraint is a constraint on versions, parsed by
+// VersionScheme.ParseConstraint. *semver.Constraints implements it.
+type VersionConstraint interface {
+	// Check returns true if the version satisfies the constraint.
+	Check(v *semver.Version) bool
+	// String returns the constraint written in its scheme.
+	String() string
+}
+
+// VersionSchemeSetter is implemented by parsers that parse or format
+// versions (e.g. a VersionParser or VersionedConfigParser).
+// Parser.WithVersionScheme passes its scheme to them, so the scheme only
+// needs to be configured in one place.
+type VersionSchemeSetter interface {
+	SetVersionScheme(scheme VersionScheme)
+}
+
+var (
+	// SemverScheme is the default version scheme, versions are semantic
+	// versions (e.g. "2.1.0" or "2.1").
+	SemverScheme VersionScheme = semverScheme{}
+	// DateScheme versions are dates in the format YYYY-MM-DD (e.g.
+	// "2024-06-01"), they are compared chronologically. Constraints support
+	// the comparison operators =, !=, >, >=, < and <= (e.g. ">= 2024-01-01,
+	// < 2025-01-01"), alternatives are separated by "||".
+	DateScheme VersionScheme = dateScheme{}
+	// IntegerScheme versions are non-negative integers (e.g. "3"), they are
+	// compared numerically. Constraints support the same operators as
+	// DateScheme (e.g. ">= 2, < 5").
+	IntegerScheme VersionScheme = integerScheme{}
+)
+
+// MustParseVersion parses a version written in the scheme and panics if it
+// is invalid. It is meant to be used to define changelogs:
+//
+//	var Changelog = evolviconf.Changelog{
+//		evolviconf.MustParseVersion(evolviconf.DateScheme, "2024-06-01"): {},
+//	}
+func MustParseVersion(scheme VersionScheme, s string) *semver.Version {
+	v, err := scheme.ParseVersion(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+type semverScheme struct{}
+
+func (semverScheme) Name() string { return "semver" }
+
+func (semverScheme) ParseVersion(s string) (*semver.Version, error) {
+	return semver.NewVersion(s)
+}
+
+// FormatVersion returns the version as it was written (e.g. "2.1" instead of
+// "2.1.0"), versions that were not parsed are formatted with all components.
+func (semverScheme) FormatVersion(v *semver.Version) string {
+	if o := v.Original(); o != "" {
+		return o
+	}
+	return v.String()
+}
+
+func (semverScheme) Compare(a, b *semver.Version) int {
+	return a.Compare(b)
+}
+
+func (semverScheme) ParseConstraint(s string) (VersionConstraint, error) {
+	c, err := semver.NewConstraint(s)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+type dateScheme struct{}
+
+func (dateScheme) Name() string { return "date" }
+
+func (dateScheme) ParseVersion(s string) (*semver.Version, error) {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date version %q: %w", s, err)
+	}
+	return semver.New(uint64(t.Year()), uint64(t.Month()), uint64(t.Day()), "", ""), nil
+}
+
+func (dateScheme) FormatVersion(v *semver.Version) string {
+	return fmt.Sprintf("%04d-%02d-%02d", v.Major(), v.Minor(), v.Patch())
+}
+
+func (dateScheme) Compare(a, b *semver.Version) int {
+	return cmp.Or(
+		cmp.Compare(a.Major(), b.Major()),
+		cmp.Compare(a.Minor(), b.Minor()),
+		cmp.Compare(a.Patch(), b.Patch()),
+	)
+}
+
+func (s dateScheme) ParseConstraint(c string) (VersionConstraint, error) {
+	return parseComparisonConstraint(s, c)
+}
+
+type integerScheme struct{}
+
+func (integerScheme) Name() string { return "integer" }
+
+func (integerScheme) ParseVersion(s string) (*semver.Version, error) {
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid integer version %q: %w", s, err)
+	}
+	return semver.New(n, 0, 0, "", ""), nil
+}
+
+func (integerScheme) FormatVersion(v *semver.Version) string {
+	return strconv.FormatUint(v.Major(), 10)
+}
+
+func (integerScheme) Compare(a, b *semver.Version) int {
+	return cmp.Compare(a.Major(), b.Major())
+}
+
+func (s integerScheme) ParseConstraint(c string) (VersionConstraint, error) {
+	return parseComparisonConstraint(s, c)
+}
+
+// comparisonConstraint is a constraint made of comparisons with versions of
+// a scheme. It is used by schemes that don't have the range operators of
+// semantic versions (^, ~ and wildcards). All comparisons of a group need to
+// be satisfied, at least one of the groups needs to be satisfied.
+type comparisonConstraint struct {
+	scheme VersionScheme
+	groups [][]versionComparison
+}
+
+type versionComparison struct {
+	op      string
+	version *semver.Version
+}
+
+// comparisonRegex matches a single comparison, a version without an operator
+// is an equality check.
+var comparisonRegex = regexp.MustCompile(`^(==|=|!=|>=|<=|>|<)?\s*(\S+)$`)
+
+func parseComparisonConstraint(scheme VersionScheme, s string) (*comparisonConstraint, error) {
+	c := &comparisonConstraint{scheme: scheme}
+	for _, group := range strings.Split(s, "||") {
+		var comparisons []versionComparison
+		for _, term := range strings.Split(group, ",") {
+			term = strings.TrimSpace(term)
+			m := comparisonRegex.FindStringSubmatch(term)
+			if m == nil || strings.ContainsAny(term, "^~*") {
+				return nil, fmt.Errorf("invalid %s version constraint %q: only the operators =, !=, >, >=, < and <= are supported", scheme.Name(), term)
+			}
+			v, err := scheme.ParseVersion(m[2])
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s version constraint %q: %w", scheme.Name(), term, err)
+			}
+			comparisons = append(comparisons, versionComparison{op: m[1], version: v})
+		}
+		c.groups = append(c.groups, comparisons)
+	}
+	return c, nil
+}
+
+func (c *comparisonConstraint) Check(v *semver.Version) bool {
+	for _, group := range c.groups {
+		if !slices.ContainsFunc(group, func(vc versionComparison) bool { return !vc.check(c.scheme, v) }) {
+			return true
+		}
+	}
+	return false
+}
+
+func (vc versionComparison) check(scheme VersionScheme, v *semver.Version) bool {
+	n := scheme.Compare(v, vc.version)
+	switch vc.op {
+	case "!=":
+		return n != 0
+	case ">":
+		return n > 0
+	case ">=":
+		return n >= 0
+	case "<":
+		return n < 0
+	case "<=":
+		return n <= 0
+	default: // "", "=" and "=="
+		return n == 0
+	}
+}
+
+func (c *comparisonConstraint) String() string {
+	groups := make([]string, len(c.groups))
+	for i, group := range c.groups {
+		terms := make([]string, len(group))
+		for j, vc := range group {
+			terms[j] = vc.op + c.scheme.FormatVersion(vc.version)
+		}
+		groups[i] = strings.Join(terms, ", ")
+	}
+	return strings.Join(groups, " || ")
+}
@@ -0,0 +1,305 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestVersionScheme(t *testing.T) {
+	testCases := []struct {
+		scheme     VersionScheme
+		version    string
+		wantSemver string
+		wantErr    bool
+	}{
+		{scheme: SemverScheme, version: "2.1", wantSemver: "2.1.0"},
+		{scheme: SemverScheme, version: "2.1.0-beta.1", wantSemver: "2.1.0-beta.1"},
+		{scheme: SemverScheme, version: "two", wantErr: true},
+		{scheme: DateScheme, version: "2024-06-01", wantSemver: "2024.6.1"},
+		{scheme: DateScheme, version: "2024-13-01", wantErr: true},
+		{scheme: DateScheme, version: "2024.6.1", wantErr: true},
+		{scheme: IntegerScheme, version: "3", wantSemver: "3.0.0"},
+		{scheme: IntegerScheme, version: "-1", wantErr: true},
+		{scheme: IntegerScheme, version: "3.1", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.scheme.Name()+"/"+tc.version, func(t *testing.T) {
+			is := is.New(t)
+			got, err := tc.scheme.ParseVersion(tc.version)
+			if tc.wantErr {
+				is.True(err != nil)
+				return
+			}
+			is.NoErr(err)
+			is.Equal(got.String(), tc.wantSemver)
+
+			formatted := tc.scheme.FormatVersion(got)
+			roundTrip, err := tc.scheme.ParseVersion(formatted)
+			is.NoErr(err)
+			is.True(roundTrip.Equal(got))
+		})
+	}
+}
+
+func TestVersionScheme_ParseConstraint(t *testing.T) {
+	testCases := []struct {
+		scheme     VersionScheme
+		constraint string
+		version    string
+		want       bool
+	}{
+		{scheme: DateScheme, constraint: ">= 2024-06-01", version: "2024-06-01", want: true},
+		{scheme: DateScheme, constraint: ">= 2024-06-01", version: "2024-12-31", want: true},
+		{scheme: DateScheme, constraint: ">= 2024-06-01", version: "2024-05-31", want: false},
+		{scheme: DateScheme, constraint: ">= 2024-01-01, < 2025-01-01", version: "2025-01-01", want: false},
+		{scheme: IntegerScheme, constraint: "3", version: "3", want: true},
+		{scheme: IntegerScheme, constraint: "3", version: "4", want: false},
+		{scheme: IntegerScheme, constraint: ">= 2, < 5", version: "4", want: true},
+		{scheme: IntegerScheme, constraint: "< 2 || > 4", version: "3", want: false},
+		{scheme: IntegerScheme, constraint: "< 2 || > 4", version: "5", want: true},
+		{scheme: IntegerScheme, constraint: "!= 3", version: "3", want: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.scheme.Name()+"/"+tc.constraint+"/"+tc.version, func(t *testing.T) {
+			is := is.New(t)
+			c, err := tc.scheme.ParseConstraint(tc.constraint)
+			is.NoErr(err)
+			is.Equal(c.Check(MustParseVersion(tc.scheme, tc.version)), tc.want)
+		})
+	}
+
+	for _, c := range []string{">= 2024-13-01", "^2024-06-01", "~2024-06-01", "2024-06-*"} {
+		_, err := DateScheme.ParseConstraint(c)
+		is.New(t).True(err != nil) // constraint should be rejected
+	}
+	for _, c := range []string{"^3", "~3", "3.x"} {
+		_, err := IntegerScheme.ParseConstraint(c)
+		is.New(t).True(err != nil) // constraint should be rejected
+	}
+
+	c, err := DateScheme.ParseConstraint(">= 2024-01-01, < 2025-01-01 || 2026-01-01")
+	is.New(t).NoErr(err)
+	is.New(t).Equal(c.String(), ">=2024-01-01, <2025-01-01 || 2026-01-01")
+}
+
+func TestVersionScheme_Compare(t *testing.T) {
+	testCases := []struct {
+		scheme VersionScheme
+		a, b   string
+		want   int
+	}{
+		{scheme: SemverScheme, a: "2.1", b: "2.1.0", want: 0},
+		{scheme: SemverScheme, a: "2.1.0-beta.1", b: "2.1.0", want: -1},
+		{scheme: DateScheme, a: "2024-06-01", b: "2024-05-31", want: 1},
+		{scheme: DateScheme, a: "2024-06-01", b: "2024-06-01", want: 0},
+		{scheme: IntegerScheme, a: "9", b: "10", want: -1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.scheme.Name()+"/"+tc.a+"/"+tc.b, func(t *testing.T) {
+			is := is.New(t)
+			a := MustParseVersion(tc.scheme, tc.a)
+			b := MustParseVersion(tc.scheme, tc.b)
+			is.Equal(tc.scheme.Compare(a, b), tc.want)
+			is.Equal(tc.scheme.Compare(b, a), -tc.want)
+		})
+	}
+}
+
+func TestParser_WithVersionScheme(t *testing.T) {
+	is := is.New(t)
+
+	constraint, err := DateScheme.ParseConstraint(">= 2024-01-01")
+	is.NoErr(err)
+	parser := NewParser[testConfig, *json.Decoder](&testParser{
+		constraint: constraint,
+		latest:     MustParseVersion(DateScheme, "2024-06-01"),
+	}).WithVersionScheme(DateScheme)
+
+	got, warnings, err := parser.Parse(context.Background(), strings.NewReader(
+		`{"version":"2024-01-15"}{"name":"a"}{"version":"2024-07-01"}`,
+	))
+	is.NoErr(err)
+	is.Equal(got[0].ParsedVersion, "2024.1.15")
+	is.Equal(got[1].ParsedVersion, "2024.6.1")
+	is.Equal(got[2].ParsedVersion, "2024.7.1")
+	is.Equal(warnings[0].Message, "no version defined, falling back to parser version 2024-06-01")
+	is.True(strings.HasPrefix(warnings[1].Message, "no parser found for version 2024-07-01, using parser for version 2024-06-01"))
+
+	_, _, err = parser.Parse(context.Background(), strings.NewReader(`{"version":"2023-12-31"}`))
+	is.Equal(err.Error(), "unsupported version 2023-12-31")
+}
+
+func TestParser_WithVersionScheme_Resolvers(t *testing.T) {
+	constraint, err := DateScheme.ParseConstraint(">= 2024-01-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newResolvers := func() []VersionResolver {
+		return []VersionResolver{
+			CommentVersionResolver("config-version"),
+			FileNameVersionResolver(regexp.MustCompile(`\.v([\w.-]+)\.json$`)),
+		}
+	}
+	newParser := func() *Parser[testConfig, *json.Decoder] {
+		return NewParser[testConfig, *json.Decoder](&testParser{
+			constraint: constraint,
+			latest:     MustParseVersion(DateScheme, "2024-06-01"),
+		})
+	}
+	parsers := map[string]*Parser[testConfig, *json.Decoder]{
+		// the scheme is passed to resolvers regardless of the order
+		"resolvers first": newParser().WithVersionResolvers(newResolvers()...).WithVersionScheme(DateScheme),
+		"scheme first":    newParser().WithVersionScheme(DateScheme).WithVersionResolvers(newResolvers()...),
+	}
+
+	testCases := []struct {
+		name    string
+		src     Source
+		want    string
+		wantErr string
+	}{{
+		name: "comment",
+		src:  Source{Header: []byte("# config-version: 2024-03-01\nname: a\n")},
+		want: "2024.3.1",
+	}, {
+		name: "file name",
+		src:  Source{Name: "pipelines.v2024-06-01.json"},
+		want: "2024.6.1",
+	}, {
+		name:    "semantic version in file name",
+		src:     Source{Name: "pipelines.v2024.6.1.json"},
+		wantErr: `failed to resolve version: invalid version in file name "pipelines.v2024.6.1.json": invalid date version "2024.6.1"`,
+	}}
+
+	for name, parser := range parsers {
+		for _, tc := range testCases {
+			t.Run(name+"/"+tc.name, func(t *testing.T) {
+				is := is.New(t)
+				got, err := parser.resolveVersion(context.Background(), tc.src)
+				if tc.wantErr != "" {
+					is.True(err != nil)
+					is.True(strings.HasPrefix(err.Error(), tc.wantErr))
+					return
+				}
+				is.NoErr(err)
+				is.Equal(got.String(), tc.want)
+			})
+		}
+	}
+
+	// documents without a version are parsed with the resolved version
+	is := is.New(t)
+	got, _, err := parsers["scheme first"].Parse(
+		ContextWithSourceName(context.Background(), "pipelines.v2024-06-01.json"),
+		strings.NewReader(`{"name":"a"}`),
+	)
+	is.NoErr(err)
+	is.Equal(got[0].ParsedVersion, "2024.6.1")
+}
+
+func TestParser_WithVersionScheme_NewerVersionPolicy(t *testing.T) {
+	constraint, err := DateScheme.ParseConstraint(">= 2024-01-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		policy  NewerVersionPolicy
+		wantErr string
+	}{{
+		policy: NewerVersionWarn,
+	}, {
+		// dates have no patch versions, a newer day in the same month is
+		// rejected as well
+		policy:  NewerVersionRejectMinor,
+		wantErr: "unsupported version 2024-06-15, latest known version is 2024-06-01 (newer version policy: reject-minor)",
+	}, {
+		policy:  NewerVersionReject,
+		wantErr: "unsupported version 2024-06-15, latest known version is 2024-06-01 (newer version policy: reject)",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.policy.String(), func(t *testing.T) {
+			is := is.New(t)
+			parser := NewParser[testConfig, *json.Decoder](&testParser{
+				constraint: constraint,
+				latest:     MustParseVersion(DateScheme, "2024-06-01"),
+			}).
+				WithVersionScheme(DateScheme).
+				WithVersionPolicy(VersionPolicy{Newer: tc.policy})
+
+			got, warnings, err := parser.Parse(context.Background(), strings.NewReader(`{"version":"2024-06-15"}`))
+			if tc.wantErr != "" {
+				is.True(errors.Is(err, ErrUnsupportedVersion))
+				is.Equal(err.Error(), tc.wantErr)
+				return
+			}
+			is.NoErr(err)
+			is.Equal(got[0].ParsedVersion, "2024.6.15")
+			is.Equal(len(warnings), 1)
+			is.True(strings.HasPrefix(warnings[0].Message, "no parser found for version 2024-06-15, using parser for version 2024-06-01"))
+		})
+	}
+
+	// the known version is accepted with every policy
+	is := is.New(t)
+	parser := NewParser[testConfig, *json.Decoder](&testParser{
+		constraint: constraint,
+		latest:     MustParseVersion(DateScheme, "2024-06-01"),
+	}).
+		WithVersionScheme(DateScheme).
+		WithVersionPolicy(VersionPolicy{Newer: NewerVersionReject})
+	_, _, err = parser.Parse(context.Background(), strings.NewReader(`{"version":"2024-06-01"}`))
+	is.NoErr(err)
+}
+
+func TestChangelogFromTagsWithScheme(t *testing.T) {
+	is := is.New(t)
+
+	type config struct {
+		Name  string `yaml:"name"`
+		Title string `yaml:"title" evolvi:"introduced=2024-06-01"`
+	}
+
+	got, err := ChangelogFromTagsWithScheme(
+		reflect.TypeFor[config](), "yaml", DateScheme,
+		MustParseVersion(DateScheme, "2024-01-01"),
+	)
+	is.NoErr(err)
+
+	index := got.Index()
+	is.Equal(len(index.Versions()), 2)
+	is.Equal(index.Latest().String(), "2024.6.1")
+	is.Equal(index.Changes(MustParseVersion(DateScheme, "2024-06-01")), []Change{{
+		Field:      "title",
+		ChangeType: FieldIntroduced,
+		Message:    "field title was introduced in version 2024-06-01, please update the config version",
+	}})
+
+	_, err = ChangelogFromTagsWithScheme(reflect.TypeFor[config](), "yaml", IntegerScheme)
+	is.True(err != nil)
+}
@@ -16,11 +16,18 @@ package evolviconf
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"log/slog"
 	"sort"
+	"strconv"
 )
 
 type Position struct {
+	// File is the file containing the position if it differs from the parsed
+	// source (e.g. an included file), empty otherwise.
+	File   string
 	Field  string
 	Line   int
 	Column int
@@ -29,27 +36,211 @@ type Position struct {
 
 type Warnings []Warning
 
+// Sort sorts the warnings by line. Warnings in the parsed source come first,
+// followed by warnings in other files (see Position.File) sorted by file.
 func (w Warnings) Sort() Warnings {
-	sort.Slice(w, func(i, j int) bool {
+	sort.SliceStable(w, func(i, j int) bool {
+		if w[i].File != w[j].File {
+			return w[i].File < w[j].File
+		}
 		return w[i].Line < w[j].Line
 	})
 	return w
 }
 
-func (w Warnings) Log(ctx context.Context, logger *slog.Logger) {
+// Log logs all warnings, see Warning.Log.
+func (w Warnings) Log(ctx context.Context, logger *slog.Logger, opts ...LogOption) {
 	for _, ww := range w {
-		ww.Log(ctx, logger)
+		ww.Log(ctx, logger, opts...)
 	}
 }
 
+// LogOption configures how warnings and differences are logged.
+type LogOption func(*logOptions)
+
+type logOptions struct {
+	redactValues bool
+	redactor     *Redactor
+}
+
+func newLogOptions(opts []LogOption) logOptions {
+	var o logOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// LogRedactedValues replaces the values of all logged warnings and
+// differences with RedactedValue, regardless of whether the field is
+// sensitive.
+func LogRedactedValues() LogOption {
+	return func(o *logOptions) {
+		o.redactValues = true
+	}
+}
+
+// LogRedactor replaces the values of logged differences with RedactedValue if
+// the field is sensitive according to r (see Redactor.Sensitive), nested
+// values of added and removed structs and maps are redacted as well. It
+// doesn't affect warnings, their values are redacted by the parser when the
+// warnings are created.
+func LogRedactor(r *Redactor) LogOption {
+	return func(o *logOptions) {
+		o.redactor = r
+	}
+}
+
+// HasErrors returns true if at least one warning has SeverityError.
+func (w Warnings) HasErrors() bool {
+	for _, ww := range w {
+		if ww.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteJSONLines writes the warnings as JSON Lines, one JSON object (see
+// Warning.MarshalJSON) per line.
+func (w Warnings) WriteJSONLines(out io.Writer) error {
+	enc := json.NewEncoder(out)
+	for _, ww := range w {
+		if err := enc.Encode(ww); err != nil {
+			return fmt.Errorf("failed to write warning: %w", err)
+		}
+	}
+	return nil
+}
+
 type Warning struct {
 	Position
 	Message string
+	// Severity of the warning, defaults to SeverityWarning.
+	Severity Severity
+	// Code identifies the kind of the warning (e.g. CodeUnknownField). It is
+	// stable across versions and can be used to filter warnings or as a rule
+	// ID in reports.
+	Code string
+	// Fix is an optional machine-applicable fix for the warning.
+	Fix *Fix
+}
+
+// Severity of a warning.
+type Severity int
+
+const (
+	// SeverityWarning is the default severity, the config can be used but
+	// should be updated.
+	SeverityWarning Severity = iota
+	// SeverityError marks warnings that should fail checks (e.g. in CI).
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	switch s {
+	case SeverityWarning, SeverityError:
+		return []byte(s.String()), nil
+	default:
+		return nil, fmt.Errorf("unknown severity %d", int(s))
+	}
 }
 
-func (w Warning) Log(ctx context.Context, logger *slog.Logger) {
+func (s *Severity) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "warning":
+		*s = SeverityWarning
+	case "error":
+		*s = SeverityError
+	default:
+		return fmt.Errorf("unknown severity %q", text)
+	}
+	return nil
+}
+
+// Warning codes of warnings produced by evolviconf.
+const (
+	// CodeUnknownField is the code of warnings about fields that don't exist
+	// in the versioned config.
+	CodeUnknownField = "unknown-field"
+	// CodeFieldIntroduced is the code of warnings about fields that were
+	// introduced in a newer version than the version of the config.
+	CodeFieldIntroduced = "field-introduced"
+	// CodeFieldDeprecated is the code of warnings about deprecated fields.
+	CodeFieldDeprecated = "field-deprecated"
+	// CodeVersionNotSpecified is the code of warnings about configs without a
+	// version.
+	CodeVersionNotSpecified = "version-not-specified"
+	// CodeVersionFallback is the code of warnings about configs parsed with a
+	// parser for an older version, because no parser supports the version.
+	CodeVersionFallback = "version-fallback"
+	// CodeVersionDeprecated is the code of warnings about configs with a
+	// deprecated version, see VersionLifecycle.
+	CodeVersionDeprecated = "version-deprecated"
+	// CodeVersionPrerelease is the code of warnings about configs with a
+	// pre-release version, see PrereleasePolicy.
+	CodeVersionPrerelease = "version-prerelease"
+	// CodeFieldLost is the code of warnings about fields that are lost when
+	// converting a config to an older version.
+	CodeFieldLost = "field-lost"
+	// CodeDefaultChanged is the code of warnings about fields that rely on a
+	// default value that changes in a newer version.
+	CodeDefaultChanged = "default-changed"
+	// CodeEnvVarNotSet is the code of warnings about environment variables
+	// referenced in a config that are not set.
+	CodeEnvVarNotSet = "env-var-not-set"
+	// CodeEnvVarInvalid is the code of warnings about malformed environment
+	// variable references.
+	CodeEnvVarInvalid = "env-var-invalid"
+)
+
+// Fix contains text edits that resolve a warning when applied to the source
+// file.
+type Fix struct {
+	// Message describes the fix (e.g. "rename field type to plugin").
+	Message string
+	Edits   []TextEdit
+}
+
+// TextEdit replaces the text in Range with NewText.
+type TextEdit struct {
+	Range   Range
+	NewText string
+}
+
+// Range is a range in a text file. Lines and columns are one-based, the end
+// is exclusive.
+type Range struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+// Log logs the warning with its position, field and value as attributes.
+func (w Warning) Log(ctx context.Context, logger *slog.Logger, opts ...LogOption) {
+	o := newLogOptions(opts)
+	if o.redactValues && w.Value != "" {
+		w.Message = MaskValue(w.Message, w.Value, RedactedValue)
+		w.Value = RedactedValue
+	}
+
 	var args []any
 
+	if w.File != "" {
+		args = append(args, slog.String("file", w.File))
+	}
 	if w.Line != 0 {
 		args = append(args, slog.Int("line", w.Line))
 	}
@@ -63,5 +254,78 @@ func (w Warning) Log(ctx context.Context, logger *slog.Logger) {
 		args = append(args, slog.String("value", w.Value))
 	}
 
-	logger.WarnContext(ctx, w.Message, args...)
+	level := slog.LevelWarn
+	if w.Severity == SeverityError {
+		level = slog.LevelError
+	}
+	logger.Log(ctx, level, w.Message, args...)
+}
+
+// String returns the warning in the format "line:column: message", the
+// position is omitted if it's unknown. If the warning refers to another file
+// the file is prepended (e.g. "connectors.yml:3:5: message").
+func (w Warning) String() string {
+	var pos string
+	switch {
+	case w.Line != 0 && w.Column != 0:
+		pos = fmt.Sprintf("%d:%d", w.Line, w.Column)
+	case w.Line != 0:
+		pos = strconv.Itoa(w.Line)
+	}
+	switch {
+	case w.File != "" && pos != "":
+		return fmt.Sprintf("%s:%s: %s", w.File, pos, w.Message)
+	case w.File != "":
+		return fmt.Sprintf("%s: %s", w.File, w.Message)
+	case pos != "":
+		return fmt.Sprintf("%s: %s", pos, w.Message)
+	default:
+		return w.Message
+	}
+}
+
+// MarshalJSON encodes the warning as a flat JSON object containing the
+// position, severity, code, message and fix of the warning. Empty values are
+// omitted.
+func (w Warning) MarshalJSON() ([]byte, error) {
+	type jsonTextEdit struct {
+		Range   Range  `json:"range"`
+		NewText string `json:"newText"`
+	}
+	type jsonFix struct {
+		Message string         `json:"message"`
+		Edits   []jsonTextEdit `json:"edits"`
+	}
+	type jsonWarning struct {
+		File     string   `json:"file,omitempty"`
+		Line     int      `json:"line,omitempty"`
+		Column   int      `json:"column,omitempty"`
+		Field    string   `json:"field,omitempty"`
+		Value    string   `json:"value,omitempty"`
+		Severity Severity `json:"severity"`
+		Code     string   `json:"code,omitempty"`
+		Message  string   `json:"message"`
+		Fix      *jsonFix `json:"fix,omitempty"`
+	}
+
+	out := jsonWarning{
+		File:     w.File,
+		Line:     w.Line,
+		Column:   w.Column,
+		Field:    w.Field,
+		Value:    w.Value,
+		Severity: w.Severity,
+		Code:     w.Code,
+		Message:  w.Message,
+	}
+	if w.Fix != nil {
+		out.Fix = &jsonFix{
+			Message: w.Fix.Message,
+			Edits:   make([]jsonTextEdit, len(w.Fix.Edits)),
+		}
+		for i, e := range w.Fix.Edits {
+			out.Fix.Edits[i] = jsonTextEdit(e)
+		}
+	}
+	return json.Marshal(out)
 }
@@ -0,0 +1,157 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+var testWarnings = Warnings{{
+	Position: Position{Field: "unknownField", Line: 6, Column: 5},
+	Message:  "field unknownField not found in type v2.Pipeline",
+	Code:     CodeUnknownField,
+}, {
+	Position: Position{Field: "type", Line: 25, Column: 9, Value: "js"},
+	Message:  "please use field 'plugin'",
+	Severity: SeverityError,
+	Code:     CodeFieldDeprecated,
+	Fix: &Fix{
+		Message: "rename field type to plugin",
+		Edits: []TextEdit{{
+			Range:   Range{StartLine: 25, StartColumn: 9, EndLine: 25, EndColumn: 13},
+			NewText: "plugin",
+		}},
+	},
+}, {
+	Message: "no version defined, falling back to parser version 2.2.0",
+	Code:    CodeVersionNotSpecified,
+}}
+
+func TestWarning_MarshalJSON(t *testing.T) {
+	is := is.New(t)
+
+	got, err := json.Marshal(testWarnings[1])
+	is.NoErr(err)
+	is.Equal(string(got), `{"line":25,"column":9,"field":"type","value":"js","severity":"error","code":"field-deprecated","message":"please use field 'plugin'","fix":{"message":"rename field type to plugin","edits":[{"range":{"startLine":25,"startColumn":9,"endLine":25,"endColumn":13},"newText":"plugin"}]}}`)
+
+	got, err = json.Marshal(Warning{Message: "custom warning"})
+	is.NoErr(err)
+	is.Equal(string(got), `{"severity":"warning","message":"custom warning"}`)
+
+	_, err = json.Marshal(Warning{Severity: Severity(5)})
+	is.True(err != nil)
+}
+
+func TestWarnings_WriteJSONLines(t *testing.T) {
+	is := is.New(t)
+
+	var out bytes.Buffer
+	err := testWarnings.WriteJSONLines(&out)
+	is.NoErr(err)
+	is.Equal(out.String(), `{"line":6,"column":5,"field":"unknownField","severity":"warning","code":"unknown-field","message":"field unknownField not found in type v2.Pipeline"}
+{"line":25,"column":9,"field":"type","value":"js","severity":"error","code":"field-deprecated","message":"please use field 'plugin'","fix":{"message":"rename field type to plugin","edits":[{"range":{"startLine":25,"startColumn":9,"endLine":25,"endColumn":13},"newText":"plugin"}]}}
+{"severity":"warning","code":"version-not-specified","message":"no version defined, falling back to parser version 2.2.0"}
+`)
+}
+
+func TestWarnings_HasErrors(t *testing.T) {
+	is := is.New(t)
+
+	is.True(testWarnings.HasErrors())
+	is.True(!testWarnings[:1].HasErrors())
+	is.True(!Warnings(nil).HasErrors())
+}
+
+func TestWriteJUnit(t *testing.T) {
+	is := is.New(t)
+
+	var out bytes.Buffer
+	err := WriteJUnit(&out, "pipelines",
+		JUnitTestCase{Name: "pipelines.yml", Warnings: testWarnings},
+		JUnitTestCase{Name: "other.yml", Warnings: testWarnings[:1]},
+		JUnitTestCase{Name: "clean.yml"},
+	)
+	is.NoErr(err)
+	is.Equal(out.String(), `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="3" failures="1">
+  <testsuite name="pipelines" tests="3" failures="1">
+    <testcase name="pipelines.yml" classname="pipelines">
+      <failure message="1 error(s)" type="error">25:9: please use field &#39;plugin&#39; (field-deprecated)</failure>
+      <system-out>6:5: field unknownField not found in type v2.Pipeline (unknown-field)&#xA;no version defined, falling back to parser version 2.2.0 (version-not-specified)</system-out>
+    </testcase>
+    <testcase name="other.yml" classname="pipelines">
+      <system-out>6:5: field unknownField not found in type v2.Pipeline (unknown-field)</system-out>
+    </testcase>
+    <testcase name="clean.yml" classname="pipelines"></testcase>
+  </testsuite>
+</testsuites>
+`)
+}
+
+func TestWarnings_WriteJUnit(t *testing.T) {
+	is := is.New(t)
+
+	var out bytes.Buffer
+	err := testWarnings[:1].WriteJUnit(&out, "pipelines.yml")
+	is.NoErr(err)
+	is.Equal(out.String(), `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="1" failures="0">
+  <testsuite name="evolviconf" tests="1" failures="0">
+    <testcase name="pipelines.yml" classname="evolviconf">
+      <system-out>6:5: field unknownField not found in type v2.Pipeline (unknown-field)</system-out>
+    </testcase>
+  </testsuite>
+</testsuites>
+`)
+}
+
+func TestWarning_String(t *testing.T) {
+	testCases := []struct {
+		warning Warning
+		want    string
+	}{
+		{warning: testWarnings[0], want: "6:5: field unknownField not found in type v2.Pipeline"},
+		{warning: testWarnings[2], want: "no version defined, falling back to parser version 2.2.0"},
+		{warning: Warning{Position: Position{Line: 3}, Message: "msg"}, want: "3: msg"},
+		{warning: Warning{Position: Position{File: "connectors.yml", Line: 3, Column: 1}, Message: "msg"}, want: "connectors.yml:3:1: msg"},
+		{warning: Warning{Position: Position{File: "connectors.yml"}, Message: "msg"}, want: "connectors.yml: msg"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.want, func(t *testing.T) {
+			is.New(t).Equal(tc.warning.String(), tc.want)
+		})
+	}
+}
+
+func TestWarnings_Sort(t *testing.T) {
+	is := is.New(t)
+
+	warnings := Warnings{
+		{Position: Position{File: "b.yml", Line: 1}},
+		{Position: Position{Line: 5}},
+		{Position: Position{File: "a.yml", Line: 2}},
+		{Position: Position{Line: 1}},
+	}.Sort()
+	is.Equal(warnings, Warnings{
+		{Position: Position{Line: 1}},
+		{Position: Position{Line: 5}},
+		{Position: Position{File: "a.yml", Line: 2}},
+		{Position: Position{File: "b.yml", Line: 1}},
+	})
+}
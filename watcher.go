@@ -0,0 +1,195 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"time"
+)
+
+const (
+	// DefaultWatchInterval is the default interval in which the Watcher polls
+	// the watched files.
+	DefaultWatchInterval = time.Second
+	// DefaultWatchDebounce is the default time the Watcher waits after the
+	// last detected change before reloading the files.
+	DefaultWatchDebounce = 500 * time.Millisecond
+)
+
+// Reload is the result of loading the watched files.
+type Reload[T any] struct {
+	// Configs contains the configs parsed from all watched files, in the order
+	// of the files. If Err is set, Configs contains the last configs that were
+	// loaded successfully (nil if there are none).
+	Configs []T
+	// Warnings contains the warnings returned by the parser.
+	Warnings Warnings
+	// Err is the error that caused the reload to fail.
+	Err error
+}
+
+// Watcher polls a set of files and parses them with a Parser every time one
+// of them changes. Changes are detected by comparing the content of the files,
+// so touching a file without changing it does not trigger a reload.
+type Watcher[T, D any] struct {
+	parser   *Parser[T, D]
+	files    []string
+	interval time.Duration
+	debounce time.Duration
+}
+
+// NewWatcher returns a watcher for the files. The files are parsed in order
+// and the configs are concatenated.
+func NewWatcher[T, D any](parser *Parser[T, D], files ...string) *Watcher[T, D] {
+	return &Watcher[T, D]{
+		parser:   parser,
+		files:    files,
+		interval: DefaultWatchInterval,
+		debounce: DefaultWatchDebounce,
+	}
+}
+
+// WithInterval sets the interval in which the files are polled.
+func (w *Watcher[T, D]) WithInterval(interval time.Duration) *Watcher[T, D] {
+	w.interval = interval
+	return w
+}
+
+// WithDebounce sets the time the watcher waits after the last detected change
+// before reloading the files. Bursts of changes (e.g. an editor writing a file
+// in multiple steps) result in a single reload.
+func (w *Watcher[T, D]) WithDebounce(debounce time.Duration) *Watcher[T, D] {
+	w.debounce = debounce
+	return w
+}
+
+// Run loads the files, calls fn with the result and then calls fn again every
+// time the files change. It blocks until ctx is canceled and returns the
+// context error. Failed reloads are reported to fn with the last good configs.
+func (w *Watcher[T, D]) Run(ctx context.Context, fn func(Reload[T])) error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	state, contents := w.snapshot()
+	last := w.reload(ctx, contents, nil)
+	fn(last)
+	// loaded is the state of the files at the last reload, intermediate
+	// states (e.g. a truncated file while it's rewritten) that are reverted
+	// within the debounce time don't trigger a reload
+	loaded := state
+
+	var (
+		pending   bool
+		changedAt time.Time
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			newState, newContents := w.snapshot()
+			if !slices.Equal(newState, state) {
+				state, contents = newState, newContents
+				pending = true
+				changedAt = now
+			}
+			if pending && now.Sub(changedAt) >= w.debounce {
+				pending = false
+				if slices.Equal(state, loaded) {
+					continue
+				}
+				loaded = state
+				last = w.reload(ctx, contents, last.Configs)
+				fn(last)
+			}
+		}
+	}
+}
+
+// Watch runs the watcher in a goroutine (see Run) and sends the results on
+// the returned channel. The channel is closed once ctx is canceled.
+func (w *Watcher[T, D]) Watch(ctx context.Context) <-chan Reload[T] {
+	out := make(chan Reload[T])
+	go func() {
+		defer close(out)
+		_ = w.Run(ctx, func(r Reload[T]) {
+			select {
+			case out <- r:
+			case <-ctx.Done():
+			}
+		})
+	}()
+	return out
+}
+
+// fileState is the state of a watched file used to detect changes.
+type fileState struct {
+	exists bool
+	hash   [sha256.Size]byte
+	err    string
+}
+
+// snapshot reads all files and returns their states and contents.
+func (w *Watcher[T, D]) snapshot() ([]fileState, []fileContent) {
+	states := make([]fileState, len(w.files))
+	contents := make([]fileContent, len(w.files))
+	for i, name := range w.files {
+		data, err := os.ReadFile(name)
+		contents[i] = fileContent{name: name, data: data, err: err}
+		switch {
+		case err == nil:
+			states[i] = fileState{exists: true, hash: sha256.Sum256(data)}
+		case errors.Is(err, fs.ErrNotExist):
+			states[i] = fileState{}
+		default:
+			states[i] = fileState{err: err.Error()}
+		}
+	}
+	return states, contents
+}
+
+type fileContent struct {
+	name string
+	data []byte
+	err  error
+}
+
+// reload parses the file contents. If parsing fails it returns the error and
+// the last good configs.
+func (w *Watcher[T, D]) reload(ctx context.Context, contents []fileContent, lastGood []T) Reload[T] {
+	var (
+		configs  []T
+		warnings Warnings
+	)
+	for _, c := range contents {
+		if c.err != nil {
+			return Reload[T]{Configs: lastGood, Err: fmt.Errorf("failed to read file %s: %w", c.name, c.err)}
+		}
+		cfgs, warn, err := w.parser.Parse(ContextWithSourceName(ctx, c.name), bytes.NewReader(c.data))
+		if err != nil {
+			return Reload[T]{Configs: lastGood, Err: fmt.Errorf("failed to parse file %s: %w", c.name, err)}
+		}
+		configs = append(configs, cfgs...)
+		warnings = append(warnings, warn...)
+	}
+	return Reload[T]{Configs: configs, Warnings: warnings}
+}
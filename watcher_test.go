@@ -0,0 +1,153 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evolviconf
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestWatcher(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir := t.TempDir()
+	file1 := filepath.Join(dir, "pipelines1.json")
+	file2 := filepath.Join(dir, "pipelines2.json")
+	writeFile(t, file1, `{"version":"1.0","name":"a"}`)
+	writeFile(t, file2, `{"version":"1.0","name":"b"}`)
+
+	parser := NewParser[testConfig, *json.Decoder](newTestParser("^1", "1.0"))
+	reloads := NewWatcher(parser, file1, file2).
+		WithInterval(5 * time.Millisecond).
+		WithDebounce(50 * time.Millisecond).
+		Watch(ctx)
+
+	// initial load
+	r := receive(t, reloads)
+	is.NoErr(r.Err)
+	is.Equal(names(r.Configs), []string{"a", "b"})
+
+	// a burst of changes results in a single reload
+	for _, name := range []string{"c", "d", "e"} {
+		writeFile(t, file2, `{"version":"1.0","name":"`+name+`"}`)
+		time.Sleep(10 * time.Millisecond)
+	}
+	r = receive(t, reloads)
+	is.NoErr(r.Err)
+	is.Equal(names(r.Configs), []string{"a", "e"})
+	expectNoReload(t, reloads)
+
+	// writing the same content does not trigger a reload
+	writeFile(t, file2, `{"version":"1.0","name":"e"}`)
+	expectNoReload(t, reloads)
+
+	// warnings are delivered with the configs
+	writeFile(t, file1, `{"name":"a"}`)
+	r = receive(t, reloads)
+	is.NoErr(r.Err)
+	is.Equal(names(r.Configs), []string{"a", "e"})
+	is.Equal(len(r.Warnings), 1)
+	is.Equal(r.Warnings[0].Code, CodeVersionNotSpecified)
+
+	// a failed reload keeps the last good configs
+	writeFile(t, file1, `{"version":`)
+	r = receive(t, reloads)
+	is.True(r.Err != nil)
+	is.Equal(names(r.Configs), []string{"a", "e"})
+
+	// a removed file is an error as well
+	is.NoErr(os.Remove(file1))
+	r = receive(t, reloads)
+	is.True(r.Err != nil)
+	is.Equal(names(r.Configs), []string{"a", "e"})
+
+	// recovering from the error
+	writeFile(t, file1, `{"version":"1.0","name":"f"}`)
+	r = receive(t, reloads)
+	is.NoErr(r.Err)
+	is.Equal(names(r.Configs), []string{"f", "e"})
+
+	// the channel is closed when the context is canceled
+	cancel()
+	select {
+	case _, ok := <-reloads:
+		is.True(!ok)
+	case <-time.After(time.Second):
+		t.Fatal("expected channel to be closed")
+	}
+}
+
+func TestWatcher_Run(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	file := filepath.Join(t.TempDir(), "missing.json")
+	parser := NewParser[testConfig, *json.Decoder](newTestParser("^1", "1.0"))
+
+	var got []Reload[testConfig]
+	err := NewWatcher(parser, file).
+		WithInterval(time.Millisecond).
+		Run(ctx, func(r Reload[testConfig]) {
+			got = append(got, r)
+			cancel()
+		})
+	is.Equal(err, context.Canceled)
+	is.Equal(len(got), 1)
+	is.True(got[0].Err != nil)
+	is.Equal(got[0].Configs, nil)
+}
+
+func names(configs []testConfig) []string {
+	out := make([]string, len(configs))
+	for i, c := range configs {
+		out[i] = c.Name
+	}
+	return out
+}
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(name, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func receive(t *testing.T, reloads <-chan Reload[testConfig]) Reload[testConfig] {
+	t.Helper()
+	select {
+	case r := <-reloads:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for reload")
+		return Reload[testConfig]{}
+	}
+}
+
+func expectNoReload(t *testing.T, reloads <-chan Reload[testConfig]) {
+	t.Helper()
+	select {
+	case r := <-reloads:
+		t.Fatalf("unexpected reload: %v", r)
+	case <-time.After(150 * time.Millisecond):
+	}
+}